@@ -17,6 +17,7 @@ package config
 import (
 	"bytes"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/gobuffalo/packr/v2"
@@ -35,6 +36,9 @@ const (
 	Test = "test"
 	// Production production env
 	Production = "production"
+
+	// DefaultDetectTarget name of the default detect target
+	DefaultDetectTarget = "default"
 )
 
 type (
@@ -42,13 +46,134 @@ type (
 		Name     string
 		Interval time.Duration
 		MaxPage  int
+		// Type 通用抓取的类型：table（默认）、json、text
+		Type             string
+		BaseURL          string
+		PageURL          string
+		AnonymityKeyword string
+		Category         string
+		// 以下为通用表格抓取的配置
+		PageSelector   string
+		RowSelector    string
+		IPIndex        int
+		PortIndex      int
+		CategoryIndex  int
+		AnonymityIndex int
+		// 以下为json抓取的配置
+		PageParam     string
+		CursorPath    string
+		CursorParam   string
+		ListPath      string
+		IPPath        string
+		PortPath      string
+		CategoryPath  string
+		AnonymityPath string
+		// 以下为文本列表抓取的配置
+		URLs []string
+	}
+	// DetectTarget target of detection
+	DetectTarget struct {
+		// Name name of the target, it's used to filter proxies
+		Name string
+		// URL url of the target
+		URL string
+		// Status expected status of response, 2xx and 3xx are valid if it's 0
+		Status int
+		// Keyword the body of response should contain the keyword
+		Keyword string
+		// Hash sha256 hex of the response body
+		Hash string
+		// Pattern the body of response should match the regexp
+		Pattern string
+		// Headers the response should contain the headers, empty value means the header only needs to exist
+		Headers map[string]string
+		// AllowTampered the tampered content of the target only fails the target, otherwise
+		// the proxy is unavailable for all targets. It should be set for the target
+		// which serves its own block or captcha page
+		AllowTampered bool
+
+		// PatternRegexp compiled regexp of pattern
+		PatternRegexp *regexp.Regexp `mapstructure:"-"`
 	}
 	// Detect detect config
 	Detect struct {
+		// URL url of the default target, it's used if targets is empty
 		URL      string
+		Targets  []DetectTarget
 		Interval time.Duration
 		Timeout  time.Duration
 		MaxTimes int
+		// SpeedBuckets boundaries of speed, the speed is the index of the first boundary greater than latency
+		SpeedBuckets []time.Duration
+		// LatencyWindow count of the latest detections used to calculate the average latency
+		LatencyWindow int
+		// Judge url of the judge, it's used to verify the anonymity of proxy
+		Judge string
+	}
+	// Seed seed config
+	Seed struct {
+		// List proxy list, e.g.: http://ip:port
+		List []string
+		// File file of proxy list, each line is a proxy
+		File string
+		// Interval reload interval
+		Interval time.Duration
+	}
+	// Store store config
+	Store struct {
+		// Type store type, the proxies are only saved in memory if it's empty
+		Type string
+		// Path file path of bolt store
+		Path string
+		// Addr address of redis
+		Addr string
+		// Password password of redis
+		Password string
+		// DB db of redis
+		DB int
+		// Prefix key prefix of redis
+		Prefix string
+		// SyncInterval reload the proxies from store interval, it's used for the shared store
+		SyncInterval time.Duration
+	}
+	// Domain domain health config
+	Domain struct {
+		// Cooldown the proxy fails for the domain is skipped during cooldown
+		Cooldown time.Duration
+		// BlockedCooldown the proxy blocked by the domain is skipped during blocked cooldown
+		BlockedCooldown time.Duration
+	}
+	// Session sticky session config
+	Session struct {
+		// TTL default ttl of session
+		TTL time.Duration
+		// MaxTTL max ttl of session
+		MaxTTL time.Duration
+	}
+	// Lease proxy lease config
+	Lease struct {
+		// TTL default ttl of lease
+		TTL time.Duration
+		// MaxTTL max ttl of lease
+		MaxTTL time.Duration
+	}
+	// Select select config
+	Select struct {
+		// Strategy default strategy of selection
+		Strategy string
+	}
+	// GeoIP geoip config
+	GeoIP struct {
+		// File mmdb file of country or city database
+		File string
+		// ASNFile mmdb file of asn database
+		ASNFile string
+	}
+	// Gateway gateway config
+	Gateway struct {
+		Listen     string
+		Timeout    time.Duration
+		MaxRetries int
 	}
 )
 
@@ -89,6 +214,14 @@ func init() {
 	}
 }
 
+// getIndex get the column index, return the default value if not set
+func getIndex(key string, defaultValue int) int {
+	if !viper.IsSet(key) {
+		return defaultValue
+	}
+	return viper.GetInt(key)
+}
+
 // GetCrawlers get crawlers config
 func GetCrawlers() []*Crawler {
 	crawlers := make([]*Crawler, 0)
@@ -101,9 +234,29 @@ func GetCrawlers() []*Crawler {
 			interval = 2 * time.Minute
 		}
 		crawlers = append(crawlers, &Crawler{
-			Name:     name,
-			Interval: interval,
-			MaxPage:  maxPage,
+			Name:             name,
+			Interval:         interval,
+			MaxPage:          maxPage,
+			Type:             viper.GetString(name + ".type"),
+			BaseURL:          viper.GetString(name + ".baseURL"),
+			PageURL:          viper.GetString(name + ".pageURL"),
+			AnonymityKeyword: viper.GetString(name + ".anonymityKeyword"),
+			Category:         viper.GetString(name + ".category"),
+			PageSelector:     viper.GetString(name + ".pageSelector"),
+			RowSelector:      viper.GetString(name + ".rowSelector"),
+			IPIndex:          viper.GetInt(name + ".ipIndex"),
+			PortIndex:        getIndex(name+".portIndex", 1),
+			CategoryIndex:    getIndex(name+".categoryIndex", -1),
+			AnonymityIndex:   getIndex(name+".anonymityIndex", -1),
+			PageParam:        viper.GetString(name + ".pageParam"),
+			CursorPath:       viper.GetString(name + ".cursorPath"),
+			CursorParam:      viper.GetString(name + ".cursorParam"),
+			ListPath:         viper.GetString(name + ".listPath"),
+			IPPath:           viper.GetString(name + ".ipPath"),
+			PortPath:         viper.GetString(name + ".portPath"),
+			CategoryPath:     viper.GetString(name + ".categoryPath"),
+			AnonymityPath:    viper.GetString(name + ".anonymityPath"),
+			URLs:             viper.GetStringSlice(name + ".urls"),
 		})
 	}
 	return crawlers
@@ -117,6 +270,25 @@ func GetDetect() *Detect {
 		URL:      viper.GetString(prefix + "url"),
 		Interval: viper.GetDuration(prefix + "interval"),
 		MaxTimes: viper.GetInt(prefix + "maxTimes"),
+
+		LatencyWindow: viper.GetInt(prefix + "latencyWindow"),
+		Judge:         viper.GetString(prefix + "judge"),
+	}
+	for _, item := range viper.GetStringSlice(prefix + "speedBuckets") {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			panic(err)
+		}
+		conf.SpeedBuckets = append(conf.SpeedBuckets, d)
+	}
+	if len(conf.SpeedBuckets) == 0 {
+		conf.SpeedBuckets = []time.Duration{
+			750 * time.Millisecond,
+			1500 * time.Millisecond,
+		}
+	}
+	if conf.LatencyWindow <= 0 {
+		conf.LatencyWindow = 5
 	}
 	if conf.Timeout == 0 {
 		conf.Timeout = 3 * time.Second
@@ -127,6 +299,24 @@ func GetDetect() *Detect {
 	if conf.URL == "" {
 		conf.URL = "https://www.baidu.com/"
 	}
+	err := viper.UnmarshalKey(prefix+"targets", &conf.Targets)
+	if err != nil {
+		panic(err)
+	}
+	for i, item := range conf.Targets {
+		if item.Pattern != "" {
+			conf.Targets[i].PatternRegexp = regexp.MustCompile(item.Pattern)
+		}
+	}
+	// 未配置检测目标，则使用默认检测地址
+	if len(conf.Targets) == 0 {
+		conf.Targets = []DetectTarget{
+			{
+				Name: DefaultDetectTarget,
+				URL:  conf.URL,
+			},
+		}
+	}
 	if conf.MaxTimes <= 0 {
 		conf.MaxTimes = 3
 	}
@@ -141,3 +331,123 @@ func GetListenAddr() string {
 	}
 	return addr
 }
+
+// GetSeed get seed config
+func GetSeed() *Seed {
+	prefix := "seed."
+	conf := &Seed{
+		List:     viper.GetStringSlice(prefix + "list"),
+		File:     viper.GetString(prefix + "file"),
+		Interval: viper.GetDuration(prefix + "interval"),
+	}
+	if conf.Interval == 0 {
+		conf.Interval = 10 * time.Minute
+	}
+	return conf
+}
+
+// GetStore get store config
+func GetStore() *Store {
+	prefix := "store."
+	return &Store{
+		Type:         viper.GetString(prefix + "type"),
+		Path:         viper.GetString(prefix + "path"),
+		Addr:         viper.GetString(prefix + "addr"),
+		Password:     viper.GetString(prefix + "password"),
+		DB:           viper.GetInt(prefix + "db"),
+		Prefix:       viper.GetString(prefix + "prefix"),
+		SyncInterval: viper.GetDuration(prefix + "syncInterval"),
+	}
+}
+
+// GetGateway get gateway config
+func GetGateway() *Gateway {
+	prefix := "gateway."
+	conf := &Gateway{
+		Listen:     viper.GetString(prefix + "listen"),
+		Timeout:    viper.GetDuration(prefix + "timeout"),
+		MaxRetries: viper.GetInt(prefix + "maxRetries"),
+	}
+	if conf.Timeout == 0 {
+		conf.Timeout = 30 * time.Second
+	}
+	if conf.MaxRetries <= 0 {
+		conf.MaxRetries = 3
+	}
+	return conf
+}
+
+// GetAuthToken get the token of api, the authorized caller can get
+// the username and password of proxy
+func GetAuthToken() string {
+	return viper.GetString("auth.token")
+}
+
+// GetGeoIP get geoip config
+func GetGeoIP() *GeoIP {
+	prefix := "geoip."
+	return &GeoIP{
+		File:    viper.GetString(prefix + "file"),
+		ASNFile: viper.GetString(prefix + "asnFile"),
+	}
+}
+
+// GetSelect get select config
+func GetSelect() *Select {
+	prefix := "select."
+	conf := &Select{
+		Strategy: viper.GetString(prefix + "strategy"),
+	}
+	if conf.Strategy == "" {
+		conf.Strategy = "random"
+	}
+	return conf
+}
+
+// GetDomain get domain health config
+func GetDomain() *Domain {
+	prefix := "domain."
+	conf := &Domain{
+		Cooldown:        viper.GetDuration(prefix + "cooldown"),
+		BlockedCooldown: viper.GetDuration(prefix + "blockedCooldown"),
+	}
+	if conf.Cooldown == 0 {
+		conf.Cooldown = 10 * time.Minute
+	}
+	if conf.BlockedCooldown == 0 {
+		conf.BlockedCooldown = time.Hour
+	}
+	return conf
+}
+
+// GetSession get sticky session config
+func GetSession() *Session {
+	prefix := "session."
+	conf := &Session{
+		TTL:    viper.GetDuration(prefix + "ttl"),
+		MaxTTL: viper.GetDuration(prefix + "maxTTL"),
+	}
+	if conf.TTL == 0 {
+		conf.TTL = 10 * time.Minute
+	}
+	if conf.MaxTTL == 0 {
+		conf.MaxTTL = time.Hour
+	}
+	return conf
+}
+
+// GetLease get proxy lease config
+func GetLease() *Lease {
+	prefix := "lease."
+	conf := &Lease{
+		TTL:    viper.GetDuration(prefix + "ttl"),
+		MaxTTL: viper.GetDuration(prefix + "maxTTL"),
+	}
+	if conf.TTL == 0 {
+		conf.TTL = 5 * time.Minute
+	}
+	if conf.MaxTTL == 0 {
+		conf.MaxTTL = time.Hour
+	}
+	return conf
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 
 	"github.com/vicanso/elton"
+	"github.com/vicanso/proxy-pool/metrics"
 	"github.com/vicanso/proxy-pool/router"
 )
 
@@ -30,10 +31,21 @@ func init() {
 	g := router.NewGroup("")
 
 	g.GET("/ping", ctrl.ping)
-
+	g.GET("/metrics", ctrl.metrics)
 }
 
 func (commonCtrl) ping(c *elton.Context) (err error) {
 	c.BodyBuffer = bytes.NewBufferString("pong")
 	return
 }
+
+// metrics get the metrics of prometheus
+func (commonCtrl) metrics(c *elton.Context) (err error) {
+	buf, err := metrics.Gather()
+	if err != nil {
+		return
+	}
+	c.SetHeader(elton.HeaderContentType, metrics.ContentType)
+	c.BodyBuffer = buf
+	return
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/vicanso/elton"
+	"github.com/vicanso/proxy-pool/crawler"
+	"github.com/vicanso/proxy-pool/router"
+)
+
+type (
+	judgeCtrl struct{}
+)
+
+func init() {
+	ctrl := judgeCtrl{}
+	g := router.NewGroup("")
+
+	g.GET("/judge", ctrl.judge)
+}
+
+// judge echo the ip, headers, tls info and protocol of the request
+func (judgeCtrl) judge(c *elton.Context) (err error) {
+	c.Body = crawler.NewJudgeResult(c.Request)
+	return
+}
@@ -15,15 +15,41 @@
 package controller
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/vicanso/elton"
+	"github.com/vicanso/hes"
+	"github.com/vicanso/proxy-pool/config"
+	"github.com/vicanso/proxy-pool/crawler"
 	"github.com/vicanso/proxy-pool/router"
 	"github.com/vicanso/proxy-pool/service"
 )
 
 type (
 	proxyCtrl struct{}
+	// reportParams params of report
+	reportParams struct {
+		IP       string `json:"ip,omitempty"`
+		Port     string `json:"port,omitempty"`
+		Category string `json:"category,omitempty"`
+		// Outcome outcome of the request: success, failure, blocked or timeout
+		Outcome string `json:"outcome,omitempty"`
+		// Host host of the target
+		Host string `json:"host,omitempty"`
+	}
+)
+
+var (
+	errInvalidStrategy  = hes.New("strategy is invalid")
+	errProxyRequired    = hes.New("ip, port and category are required")
+	errInvalidTTL       = hes.New("ttl is invalid")
+	errInvalidAnonymity = hes.New("anonymity is invalid")
+	errInvalidASN       = hes.New("asn is invalid")
 )
 
 func init() {
@@ -32,21 +58,97 @@ func init() {
 
 	g.GET("", ctrl.list)
 	g.GET("/one", ctrl.findOne)
+	g.POST("/report", ctrl.report)
+	g.POST("/lease", ctrl.lease)
+	g.DELETE("/lease/{id}", ctrl.release)
+}
+
+// isAuthorized test whether or not the caller is authorized
+func isAuthorized(c *elton.Context) bool {
+	token := config.GetAuthToken()
+	if token == "" {
+		return false
+	}
+	value := c.GetRequestHeader("Authorization")
+	return subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+token)) == 1
+}
+
+// redact remove the username and password of proxy if the caller isn't authorized
+func redact(c *elton.Context, list ...*crawler.Proxy) []*crawler.Proxy {
+	if isAuthorized(c) {
+		return list
+	}
+	result := make([]*crawler.Proxy, len(list))
+	for i, p := range list {
+		result[i] = p.Redact()
+	}
+	return result
+}
+
+// getFilters get the proxy filters from query
+func getFilters(c *elton.Context) (filters []crawler.ProxyFilter, err error) {
+	filters = make([]crawler.ProxyFilter, 0)
+	source := c.QueryParam("source")
+	if source != "" {
+		filters = append(filters, crawler.NewSourceFilter(source))
+	}
+	anonymity := c.QueryParam("anonymity")
+	if anonymity != "" {
+		if !crawler.IsValidAnonymity(anonymity) {
+			err = errInvalidAnonymity
+			return
+		}
+		filters = append(filters, crawler.NewAnonymityFilter(anonymity))
+	}
+	country := c.QueryParam("country")
+	if country != "" {
+		filters = append(filters, crawler.NewCountryFilter(country))
+	}
+	// 支持AS4134与4134两种形式
+	asn := strings.TrimPrefix(strings.ToUpper(c.QueryParam("asn")), "AS")
+	if asn != "" {
+		v, e := strconv.ParseUint(asn, 10, 32)
+		if e != nil {
+			err = errInvalidASN
+			return
+		}
+		filters = append(filters, crawler.NewASNFilter(uint(v)))
+	}
+	domain := c.QueryParam("domain")
+	if domain != "" {
+		filters = append(filters, service.NewDomainFilter(domain))
+	}
+	target := c.QueryParam("target")
+	if target != "" {
+		filters = append(filters, crawler.NewTargetFilter(target))
+	}
+	// 最大延时，单位毫秒
+	maxLatency, _ := strconv.Atoi(c.QueryParam("maxLatency"))
+	if maxLatency > 0 {
+		filters = append(filters, crawler.NewLatencyFilter(time.Duration(maxLatency)*time.Millisecond))
+	}
+	return
 }
 
 // list get all available proxy
 func (proxyCtrl) list(c *elton.Context) (err error) {
-	c.CacheMaxAge("1m")
+	filters, err := getFilters(c)
+	if err != nil {
+		return
+	}
+	// 通过鉴权的返回数据包括账号密码，不可被缓存
+	if !isAuthorized(c) {
+		c.CacheMaxAge("1m")
+	}
 	// 直接返回所有可用的proxy，暂不考虑分页等处理
 	c.Body = map[string]interface{}{
-		"proxies": service.GetAvailableProxyList(),
+		"proxies": redact(c, service.GetAvailableProxyList(filters...)...),
 	}
 	return
 }
 
-// findOne get one available proxy
-func (proxyCtrl) findOne(c *elton.Context) (err error) {
-	category := c.QueryParam("category")
+// getFindOptions get the options of finding proxy from query
+func getFindOptions(c *elton.Context) (opts crawler.FindOptions, err error) {
 	speed := -1
 	sp := c.QueryParam("speed")
 	if sp != "" {
@@ -55,11 +157,137 @@ func (proxyCtrl) findOne(c *elton.Context) (err error) {
 			speed = v
 		}
 	}
-	p := service.GetAvailableProxy(category, speed)
+	strategy := c.QueryParam("strategy")
+	if strategy != "" && !crawler.IsValidStrategy(strategy) {
+		err = errInvalidStrategy
+		return
+	}
+	opts = crawler.FindOptions{
+		Category:     c.QueryParam("category"),
+		Speed:        int32(speed),
+		DistinctExit: c.QueryParam("distinctExit") == "true",
+		Strategy:     strategy,
+	}
+	return
+}
+
+// getTTL get the ttl from query, 0 is returned if it isn't set
+func getTTL(c *elton.Context) (ttl time.Duration, err error) {
+	value := c.QueryParam("ttl")
+	if value == "" {
+		return
+	}
+	ttl, err = time.ParseDuration(value)
+	if err != nil {
+		err = errInvalidTTL
+	}
+	return
+}
+
+// findOne get one available proxy
+func (proxyCtrl) findOne(c *elton.Context) (err error) {
+	opts, err := getFindOptions(c)
+	if err != nil {
+		return
+	}
+	filters, err := getFilters(c)
+	if err != nil {
+		return
+	}
+	var p *crawler.Proxy
+	// 指定会话的则在有效期内返回同一代理
+	if session := c.QueryParam("session"); session != "" {
+		var ttl time.Duration
+		ttl, err = getTTL(c)
+		if err != nil {
+			return
+		}
+		p = service.FindSessionProxy(session, ttl, opts, filters...)
+	} else {
+		p = service.FindAvailableProxy(opts, filters...)
+	}
 	if p == nil {
 		c.NoContent()
 		return
 	}
-	c.Body = p
+	c.Body = redact(c, p)[0]
+	return
+}
+
+// lease check out one proxy exclusively until it's released or expired
+func (proxyCtrl) lease(c *elton.Context) (err error) {
+	opts, err := getFindOptions(c)
+	if err != nil {
+		return
+	}
+	ttl, err := getTTL(c)
+	if err != nil {
+		return
+	}
+	filters, err := getFilters(c)
+	if err != nil {
+		return
+	}
+	l, err := service.LeaseProxy(ttl, opts, filters...)
+	switch err {
+	case nil:
+	case crawler.ErrLeaseUnsupported:
+		err = hes.NewWithErrorStatusCode(err, http.StatusNotImplemented)
+		return
+	default:
+		err = hes.NewWithErrorStatusCode(err, http.StatusInternalServerError)
+		return
+	}
+	if l == nil {
+		c.NoContent()
+		return
+	}
+	c.Created(&crawler.Lease{
+		ID:        l.ID,
+		Proxy:     redact(c, l.Proxy)[0],
+		ExpiredAt: l.ExpiredAt,
+	})
+	return
+}
+
+// release release the leased proxy, the outcome of the usage is reported if it's set
+func (proxyCtrl) release(c *elton.Context) (err error) {
+	err = service.ReleaseProxy(c.Param("id"), c.QueryParam("outcome"), c.QueryParam("host"))
+	switch err {
+	case nil:
+		c.NoContent()
+	case crawler.ErrLeaseNotFound:
+		err = hes.NewWithErrorStatusCode(err, http.StatusNotFound)
+	default:
+		err = hes.Wrap(err)
+	}
+	return
+}
+
+// report report the outcome of the request through proxy
+func (proxyCtrl) report(c *elton.Context) (err error) {
+	params := reportParams{}
+	err = json.Unmarshal(c.RequestBody, &params)
+	if err != nil {
+		err = hes.Wrap(err)
+		return
+	}
+	if params.IP == "" || params.Port == "" || params.Category == "" {
+		err = errProxyRequired
+		return
+	}
+	err = service.ReportProxy(&crawler.Proxy{
+		IP:       params.IP,
+		Port:     params.Port,
+		Category: params.Category,
+	}, params.Outcome, params.Host)
+	switch err {
+	case nil:
+		c.NoContent()
+	case crawler.ErrProxyNotFound:
+		err = hes.NewWithErrorStatusCode(err, http.StatusNotFound)
+	default:
+		err = hes.Wrap(err)
+	}
 	return
 }
@@ -0,0 +1,40 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/vicanso/elton"
+	"github.com/vicanso/proxy-pool/router"
+	"github.com/vicanso/proxy-pool/service"
+)
+
+type (
+	statsCtrl struct{}
+)
+
+func init() {
+	ctrl := statsCtrl{}
+	g := router.NewGroup("/stats")
+
+	g.GET("/sources", ctrl.listSource)
+}
+
+// listSource get the yield stats of proxy sources
+func (statsCtrl) listSource(c *elton.Context) (err error) {
+	c.Body = map[string]interface{}{
+		"sources": service.GetSourceStats(),
+	}
+	return
+}
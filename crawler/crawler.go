@@ -19,7 +19,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"net/url"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,6 +28,7 @@ import (
 	"github.com/vicanso/go-axios"
 	"github.com/vicanso/proxy-pool/config"
 	"github.com/vicanso/proxy-pool/log"
+	"github.com/vicanso/proxy-pool/metrics"
 	"go.uber.org/zap"
 )
 
@@ -44,13 +45,16 @@ const (
 const (
 	defaultUserAgent     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36"
 	defaulttProxyTimeout = 10 * time.Second
+	// 连续失败达到此次数的代理则删除
+	maxProxyFails = 3
+	// 重新检测可用代理的锁
+	redetectLockName = "redetect"
 )
 
 var (
-	speedDevides = []time.Duration{750 * time.Millisecond, 1500 * time.Millisecond}
-
 	logger       = log.Default()
 	detectConfig = config.GetDetect()
+	domainConfig = config.GetDomain()
 )
 
 type (
@@ -63,10 +67,16 @@ type (
 		avaliableProxyList         ProxyList
 		newProxyDetectStatus       int32
 		availableProxyDetectStatus int32
+		geoResolver                GeoResolver
+		domainHealth               domainHealthTable
+		sessions                   sessionTable
+		leases                     leaseTable
 	}
 	// baseProxyCrawler base proxy crawler
 	// nolint
 	baseProxyCrawler struct {
+		// 名称，作为代理的来源
+		name string
 		// 每次抓取代理信息间隔（需要注意不同的网站对访问频率有不同的限制，不要设置太短）
 		interval time.Duration
 		// axios http实例
@@ -100,13 +110,28 @@ func (bp *baseProxyCrawler) OnFetch(fn FetchListener) {
 	bp.fetchListener = fn
 }
 
+// emit emit the proxy to fetch listener, the source and seen time are filled in
+func (bp *baseProxyCrawler) emit(p *Proxy) {
+	if bp.fetchListener == nil {
+		return
+	}
+	if p.Source == "" {
+		p.Source = bp.name
+	}
+	now := time.Now().Unix()
+	p.FirstSeenAt = now
+	p.LastSeenAt = now
+	atomic.AddInt64(&getSourceStats(bp.name).Parsed, 1)
+	bp.fetchListener(p)
+}
+
 // Stop stop the crawler
 func (bp *baseProxyCrawler) Stop() {
 	atomic.StoreInt32(&bp.status, StatusStop)
 }
 
-// fetchPage fetch html content of the current page
-func (bp *baseProxyCrawler) fetchPage(name, urlTemplate string) (doc *goquery.Document, err error) {
+// fetchData fetch data of the current page
+func (bp *baseProxyCrawler) fetchData(urlTemplate string) (data []byte, err error) {
 	ins := bp.ins
 	// 至最后一页则重置页码
 	if bp.maxPage != 0 && bp.currentPage == bp.maxPage {
@@ -114,23 +139,42 @@ func (bp *baseProxyCrawler) fetchPage(name, urlTemplate string) (doc *goquery.Do
 		bp.maxPage = 0
 	}
 	bp.currentPage++
-	resp, err := ins.Get(fmt.Sprintf(urlTemplate, bp.currentPage))
+	pageURL := urlTemplate
+	// 无分页的地址则直接使用
+	if strings.Contains(urlTemplate, "%d") {
+		pageURL = fmt.Sprintf(urlTemplate, bp.currentPage)
+	}
+	resp, err := ins.Get(pageURL)
+	stats := getSourceStats(bp.name)
 	// 对于抓取失败，则直接退出
 	if err != nil ||
 		resp.Status != http.StatusOK ||
 		len(resp.Data) == 0 {
+		atomic.AddInt64(&stats.FetchFails, 1)
+		metrics.IncCrawlerFetch(bp.name, false)
 		logger.Error("get proxy list fail",
-			zap.String("name", name),
+			zap.String("name", bp.name),
 			zap.Int("page", bp.currentPage),
 			zap.Error(err),
 		)
 		return
 	}
+	atomic.AddInt64(&stats.Pages, 1)
+	metrics.IncCrawlerFetch(bp.name, true)
 	logger.Info("get proxy list success",
-		zap.String("name", name),
+		zap.String("name", bp.name),
 		zap.Int("page", bp.currentPage),
 	)
-	return goquery.NewDocumentFromReader(bytes.NewReader(resp.Data))
+	return resp.Data, nil
+}
+
+// fetchPage fetch html content of the current page
+func (bp *baseProxyCrawler) fetchPage(urlTemplate string) (doc *goquery.Document, err error) {
+	data, err := bp.fetchData(urlTemplate)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	return goquery.NewDocumentFromReader(bytes.NewReader(data))
 }
 
 // LimitMaxPage set limit max page
@@ -138,67 +182,149 @@ func (bp *baseProxyCrawler) LimitMaxPage(value int) {
 	bp.limitMaxPage = value
 }
 
+// NewProxyTransport create a new http transport with proxy
+func NewProxyTransport(p *Proxy) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       10 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	switch p.Category {
+	case CategorySOCKS4:
+		// http.Transport不支持socks4，使用自定义的dialer（socks4只有user id）
+		transport.DialContext = newSocks4Dialer(p.Addr(), p.Username, dialer).DialContext
+		return transport
+	case CategorySOCKS5:
+		// http.Transport支持socks5的proxy
+		transport.Proxy = http.ProxyURL(p.URL("socks5"))
+		return transport
+	}
+	transport.Proxy = http.ProxyURL(p.URL("http"))
+	return transport
+}
+
 // NewProxyClient create a new http client with proxy
 func NewProxyClient(p *Proxy) *http.Client {
-	proxyURL, _ := url.Parse(fmt.Sprintf("http://%s:%s", p.IP, p.Port))
-	if proxyURL == nil {
+	transport := NewProxyTransport(p)
+	if transport == nil {
 		return nil
 	}
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: func(_ *http.Request) (*url.URL, error) {
-				return proxyURL, nil
-			},
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       10 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
+		Transport: transport,
 	}
 }
 
-// analyze check the proxy is available and speed
+// analyze check the proxy is available and speed, the proxy is available if any of
+// the targets is passed and the content of the target rejecting tampering isn't tampered
 func (c *Crawler) analyze(p *Proxy) (available bool) {
 	httpClient := NewProxyClient(p)
 	if httpClient == nil {
 		return false
 	}
-	// 多次检测，只要一次成功则认为成功
-	for i := 0; i < detectConfig.MaxTimes; i++ {
-		ins := axios.NewInstance(&axios.InstanceConfig{
-			Timeout: detectConfig.Timeout,
-			Client:  httpClient,
-		})
-		startedAt := time.Now()
-		resp, err := ins.Get(detectConfig.URL)
-		if err != nil {
-			continue
-		}
-		if resp.Status >= http.StatusOK && resp.Status < http.StatusBadRequest {
-			d := time.Since(startedAt)
-			atomic.StoreInt32(&p.Speed, int32(len(speedDevides)))
-			// 将当前proxy划分对应的分段
-			for index, item := range speedDevides {
-				if d < item {
-					atomic.StoreInt32(&p.Speed, int32(index))
+	detectStartedAt := time.Now()
+	defer func() {
+		metrics.ObserveDetect(time.Since(detectStartedAt), available)
+	}()
+	ins := axios.NewInstance(&axios.InstanceConfig{
+		Timeout: detectConfig.Timeout,
+		Client:  httpClient,
+	})
+	result := &detectResult{
+		targets: make([]string, 0, len(detectConfig.Targets)),
+	}
+	// 检测结果在完成后统一更新，避免获取代理时读取到检测中的数据
+	defer p.publish(result)
+	for _, target := range detectConfig.Targets {
+		// 多次检测，只要一次成功则认为成功
+		for i := 0; i < detectConfig.MaxTimes; i++ {
+			startedAt := time.Now()
+			resp, err := ins.Get(target.URL)
+			if err != nil {
+				continue
+			}
+			err = validate(target, resp)
+			if err == errContentTampered {
+				logger.Info("proxy content is tampered",
+					zap.String("proxy", p.Key()),
+					zap.String("target", target.Name),
+				)
+				// 指定了允许篡改的目标，只是该目标检测失败（可能是目标网站对该代理返回的验证页），无需再重试
+				if target.AllowTampered {
 					break
 				}
+				// 否则代理直接标记为不可用
+				available = false
+				result.targets = nil
+				return
+			}
+			if err != nil {
+				continue
+			}
+			// 以第一个通过的目标的延时为准
+			if !available {
+				p.recordLatency(time.Since(startedAt), detectConfig.LatencyWindow, detectConfig.SpeedBuckets)
 			}
 			available = true
+			result.targets = append(result.targets, target.Name)
 			break
 		}
 	}
+	if !available {
+		return
+	}
+	// 可用的代理通过judge检测其匿名度
+	exitIP := p.exitKey()
+	if detectConfig.Judge != "" {
+		ip, anonymity, err := verifyAnonymity(ins, detectConfig.Judge)
+		if err != nil {
+			logger.Debug("verify anonymity fail",
+				zap.String("proxy", p.Key()),
+				zap.Error(err),
+			)
+		} else {
+			exitIP = ip
+			result.exitIP = ip
+			result.anonymity = anonymity
+		}
+	}
+	// 可用的代理获取其出口IP的地理信息
+	if c.geoResolver != nil {
+		result.geo = c.resolveGeo(exitIP)
+	}
 	return
 }
 
+// resolveGeo get the geo info of proxy's exit ip, nil is returned if fail
+func (c *Crawler) resolveGeo(exitIP string) *GeoInfo {
+	ip := net.ParseIP(exitIP)
+	if ip == nil {
+		return nil
+	}
+	info, err := c.geoResolver.Resolve(ip)
+	if err != nil {
+		logger.Debug("resolve geo fail",
+			zap.String("ip", ip.String()),
+			zap.Error(err),
+		)
+		return nil
+	}
+	return info
+}
+
 // addNewProxy add proxy to new proxy list
 func (c *Crawler) addNewProxy(p *Proxy) {
+	// 已在可用列表中的则更新其抓取时间，无需再检测
+	if c.avaliableProxyList.Touch(p) {
+		return
+	}
 	c.newProxyList.Add(p)
 }
 
@@ -209,19 +335,32 @@ func (c *Crawler) detectProxyList(list []*Proxy) (availableList []*Proxy, unavai
 	w := sync.WaitGroup{}
 	// 控制最多检测proxy的数量
 	chans := make(chan bool, 5)
+	mu := sync.Mutex{}
 	for _, item := range list {
 		w.Add(1)
 		go func(p *Proxy) {
+			defer w.Done()
+			// 正在检测中（如客户端反馈失败触发的检测）则跳过
+			if !atomic.CompareAndSwapInt32(&p.detecting, 0, 1) {
+				return
+			}
+			defer atomic.StoreInt32(&p.detecting, 0)
 			chans <- true
 			avaliable := c.analyze(p)
 			atomic.StoreInt64(&p.DetectedAt, time.Now().Unix())
+			if avaliable {
+				atomic.AddInt64(&p.Successes, 1)
+			} else {
+				atomic.AddInt64(&p.Failures, 1)
+			}
+			mu.Lock()
 			if avaliable {
 				availableList = append(availableList, p)
 			} else {
 				unavailableList = append(unavailableList, p)
 			}
+			mu.Unlock()
 			<-chans
-			w.Done()
 		}(item)
 	}
 	w.Wait()
@@ -237,6 +376,9 @@ func (c *Crawler) detectNewProxy() {
 	}
 	proxyList := c.newProxyList.Reset()
 	availableList, _ := c.detectProxyList(proxyList)
+	for _, p := range availableList {
+		atomic.AddInt64(&getSourceStats(p.Source).Available, 1)
+	}
 	c.avaliableProxyList.Add(availableList...)
 
 	atomic.StoreInt32(&c.newProxyDetectStatus, detectStop)
@@ -252,6 +394,32 @@ func (c *Crawler) RedetectAvailableProxy() {
 	if old == detectRunning {
 		return
 	}
+	defer atomic.StoreInt32(&c.availableProxyDetectStatus, detectStop)
+	// 清除长时间未更新的目标网站健康记录
+	c.domainHealth.prune()
+	// 清除已无代理使用的出口IP的选择记录
+	c.avaliableProxyList.pruneExits()
+	// 多实例共享存储时，只有获取到锁的实例才执行检测，其它实例从存储中重新加载
+	ttl := detectConfig.Interval / 2
+	locked, err := c.avaliableProxyList.Acquire(redetectLockName, ttl)
+	if err != nil || !locked {
+		if err != nil {
+			logger.Error("get redetect lock fail",
+				zap.Error(err),
+			)
+		}
+		_ = c.SyncAvailableProxy()
+		return
+	}
+	// 检测期间定时续期，避免锁过期后其它实例同时检测，完成后锁在ttl后过期，
+	// 保证每个周期只有一个实例检测
+	done := make(chan struct{})
+	defer close(done)
+	go c.keepRedetectLock(ttl, done)
+	// 多实例共享存储时，检测前先从存储中加载，包括其它实例新增的代理
+	if c.avaliableProxyList.Shared() {
+		_ = c.SyncAvailableProxy()
+	}
 	proxyList := c.avaliableProxyList.List()
 	availableList, unavailableList := c.detectProxyList(proxyList)
 
@@ -263,14 +431,57 @@ func (c *Crawler) RedetectAvailableProxy() {
 	failProxyList := make([]*Proxy, 0)
 	for _, p := range unavailableList {
 		count := atomic.AddInt32(&p.Fails, 1)
-		if count >= 3 {
+		if count >= maxProxyFails {
 			failProxyList = append(failProxyList, p)
+			atomic.AddInt64(&getSourceStats(p.Source).Evicted, 1)
 		}
 	}
 	// 对于三次检测失败的代理则删除
 	c.avaliableProxyList.Remove(failProxyList...)
+	// 保存检测后的状态（检测时间、速度以及失败次数）
+	c.avaliableProxyList.Save(proxyList...)
+}
 
-	atomic.StoreInt32(&c.availableProxyDetectStatus, detectStop)
+// keepRedetectLock extend the redetect lock every half of ttl until done
+func (c *Crawler) keepRedetectLock(ttl time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			extended, err := c.avaliableProxyList.Extend(redetectLockName, ttl)
+			if err != nil || !extended {
+				logger.Error("extend redetect lock fail",
+					zap.Bool("extended", extended),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// SyncAvailableProxy reload the available proxy list from store
+func (c *Crawler) SyncAvailableProxy() (err error) {
+	err = c.avaliableProxyList.Reload()
+	if err != nil {
+		logger.Error("reload available proxy fail",
+			zap.Error(err),
+		)
+	}
+	return
+}
+
+// SetGeoResolver set the geo resolver, the geo info of proxy is resolved after detection passes
+func (c *Crawler) SetGeoResolver(r GeoResolver) {
+	c.geoResolver = r
+}
+
+// SetStore set the store of available proxy list,
+// the proxies saved in store will be loaded
+func (c *Crawler) SetStore(store ProxyStore) error {
+	return c.avaliableProxyList.SetStore(store)
 }
 
 // Start start fetch proxy
@@ -287,11 +498,21 @@ func (c *Crawler) Start(crawlers ...ProxyCrawler) {
 }
 
 // GetAvailableProxyList get available proxy list
-func (c *Crawler) GetAvailableProxyList() []*Proxy {
-	return c.avaliableProxyList.List()
+func (c *Crawler) GetAvailableProxyList(filters ...ProxyFilter) []*Proxy {
+	return c.avaliableProxyList.Filter(filters...)
+}
+
+// GetNewProxyCount get the count of new proxies waiting for detection
+func (c *Crawler) GetNewProxyCount() int {
+	return c.newProxyList.Size()
 }
 
 // GetAvailableProxy get available proxy
-func (c *Crawler) GetAvailableProxy(category string, speed int32) *Proxy {
-	return c.avaliableProxyList.FindOne(category, speed)
+func (c *Crawler) GetAvailableProxy(category string, speed int32, filters ...ProxyFilter) *Proxy {
+	return c.avaliableProxyList.FindOne(category, speed, filters...)
+}
+
+// FindAvailableProxy find available proxy matches the options
+func (c *Crawler) FindAvailableProxy(opts FindOptions, filters ...ProxyFilter) *Proxy {
+	return c.avaliableProxyList.Find(opts, filters...)
 }
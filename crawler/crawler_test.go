@@ -15,6 +15,10 @@
 package crawler
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/vicanso/go-axios"
@@ -39,7 +43,7 @@ func TestBaseProxyCrawler(t *testing.T) {
 		Status: 200,
 		Data:   []byte(""),
 	})
-	doc, err := bp.fetchPage("", "%d")
+	doc, err := bp.fetchPage("%d")
 	assert.Nil(err)
 	assert.Nil(doc)
 	assert.Equal(0, bp.maxPage)
@@ -47,3 +51,53 @@ func TestBaseProxyCrawler(t *testing.T) {
 	done()
 }
 
+func TestNewProxyClientAuth(t *testing.T) {
+	assert := assert.New(t)
+	// 模拟需要认证的http代理
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	p := &Proxy{
+		IP:       host,
+		Port:     port,
+		Category: CategoryHTTP,
+	}
+	resp, err := NewProxyClient(p).Get("http://example.com/")
+	assert.Nil(err)
+	assert.Equal(http.StatusProxyAuthRequired, resp.StatusCode)
+
+	p.Username = "user"
+	p.Password = "pass"
+	resp, err = NewProxyClient(p).Get("http://example.com/")
+	assert.Nil(err)
+	assert.Equal(http.StatusNoContent, resp.StatusCode)
+}
+
+// loadCountStore store for test, count the load times
+type loadCountStore struct {
+	testStore
+	loads int32
+}
+
+func (s *loadCountStore) Load() ([]*Proxy, error) {
+	atomic.AddInt32(&s.loads, 1)
+	return nil, nil
+}
+
+func TestRedetectWithoutSharedStore(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	store := new(loadCountStore)
+	assert.Nil(c.SetStore(store))
+	loads := atomic.LoadInt32(&store.loads)
+
+	// 非共享的存储，检测前不从存储中重新加载
+	c.RedetectAvailableProxy()
+	assert.Equal(loads, atomic.LoadInt32(&store.loads))
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/vicanso/go-axios"
+	"github.com/vicanso/proxy-pool/config"
+)
+
+var (
+	errUnexpectedStatus = errors.New("unexpected status")
+	// errContentTampered the status is expected but the content is modified,
+	// the proxy may be a login page, an ad injection or a captive portal
+	errContentTampered = errors.New("content is tampered")
+)
+
+// validate test whether or not the response matches the detect target
+func validate(target config.DetectTarget, resp *axios.Response) error {
+	if target.Status != 0 {
+		if resp.Status != target.Status {
+			return errUnexpectedStatus
+		}
+	} else if resp.Status < http.StatusOK || resp.Status >= http.StatusBadRequest {
+		return errUnexpectedStatus
+	}
+	if target.Keyword != "" && !bytes.Contains(resp.Data, []byte(target.Keyword)) {
+		return errContentTampered
+	}
+	if target.Hash != "" {
+		sum := sha256.Sum256(resp.Data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), target.Hash) {
+			return errContentTampered
+		}
+	}
+	if target.PatternRegexp != nil && !target.PatternRegexp.Match(resp.Data) {
+		return errContentTampered
+	}
+	for key, value := range target.Headers {
+		values, ok := resp.Headers[http.CanonicalHeaderKey(key)]
+		if !ok {
+			return errContentTampered
+		}
+		if value != "" && !containsString(values, value) {
+			return errContentTampered
+		}
+	}
+	return nil
+}
+
+// containsString test whether or not the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/go-axios"
+	"github.com/vicanso/proxy-pool/config"
+)
+
+// newTestHTTPProxy create a http proxy for test, the response is decided by the host of target
+func newTestHTTPProxy(fn http.HandlerFunc) (*httptest.Server, *Proxy) {
+	server := httptest.NewServer(fn)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return server, &Proxy{
+		IP:       host,
+		Port:     port,
+		Category: CategoryHTTP,
+	}
+}
+
+func TestAnalyzeTargets(t *testing.T) {
+	assert := assert.New(t)
+	server, p := newTestHTTPProxy(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Host {
+		case "a.com":
+			_, _ = w.Write([]byte("hello a"))
+		case "b.com":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	defer server.Close()
+
+	originalTargets := detectConfig.Targets
+	defer func() {
+		detectConfig.Targets = originalTargets
+	}()
+	detectConfig.Targets = []config.DetectTarget{
+		{
+			Name:    "a",
+			URL:     "http://a.com/",
+			Keyword: "hello",
+		},
+		{
+			Name: "b",
+			URL:  "http://b.com/",
+		},
+		{
+			Name:    "c",
+			URL:     "http://c.com/",
+			Keyword: "hello",
+		},
+	}
+	c := new(Crawler)
+	assert.True(c.analyze(p))
+	assert.Equal([]string{"a"}, p.Targets)
+	assert.NotEqual(int64(0), p.AvgLatency)
+	assert.True(NewTargetFilter("a")(p))
+	assert.False(NewTargetFilter("b")(p))
+
+	// 所有目标均失败则不可用
+	detectConfig.Targets = detectConfig.Targets[1:]
+	assert.False(c.analyze(p))
+	assert.Equal(0, len(p.Targets))
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	header := make(http.Header)
+	header.Set("X-Powered-By", "test")
+	resp := &axios.Response{
+		Status:  http.StatusOK,
+		Headers: header,
+		Data:    []byte("hello world"),
+	}
+	sum := sha256.Sum256(resp.Data)
+
+	tests := []struct {
+		target config.DetectTarget
+		err    error
+	}{
+		{
+			target: config.DetectTarget{},
+		},
+		{
+			target: config.DetectTarget{
+				Status: http.StatusNoContent,
+			},
+			err: errUnexpectedStatus,
+		},
+		{
+			target: config.DetectTarget{
+				Keyword: "login",
+			},
+			err: errContentTampered,
+		},
+		{
+			target: config.DetectTarget{
+				Hash: hex.EncodeToString(sum[:]),
+			},
+		},
+		{
+			target: config.DetectTarget{
+				Hash: "abcd",
+			},
+			err: errContentTampered,
+		},
+		{
+			target: config.DetectTarget{
+				PatternRegexp: regexp.MustCompile(`^hello \w+$`),
+			},
+		},
+		{
+			target: config.DetectTarget{
+				PatternRegexp: regexp.MustCompile(`^login`),
+			},
+			err: errContentTampered,
+		},
+		{
+			target: config.DetectTarget{
+				Headers: map[string]string{
+					"x-powered-by": "",
+				},
+			},
+		},
+		{
+			target: config.DetectTarget{
+				Headers: map[string]string{
+					"x-powered-by": "nginx",
+				},
+			},
+			err: errContentTampered,
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.err, validate(tt.target, resp))
+	}
+}
+
+func TestAnalyzeTampered(t *testing.T) {
+	assert := assert.New(t)
+	server, p := newTestHTTPProxy(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "a.com" {
+			_, _ = w.Write([]byte("hello a"))
+			return
+		}
+		// 其它网站返回注入广告后的内容
+		_, _ = w.Write([]byte("<script>ad</script>hello b"))
+	})
+	defer server.Close()
+
+	originalTargets := detectConfig.Targets
+	defer func() {
+		detectConfig.Targets = originalTargets
+	}()
+	detectConfig.Targets = []config.DetectTarget{
+		{
+			Name: "a",
+			URL:  "http://a.com/",
+		},
+		{
+			Name:          "b",
+			URL:           "http://b.com/",
+			PatternRegexp: regexp.MustCompile(`^hello`),
+			AllowTampered: true,
+		},
+	}
+	c := new(Crawler)
+	// 指定允许篡改的目标，只是该目标检测失败
+	assert.True(c.analyze(p))
+	assert.Equal([]string{"a"}, p.Targets)
+
+	// 默认内容被篡改则代理不可用
+	detectConfig.Targets[1].AllowTampered = false
+	assert.False(c.analyze(p))
+	assert.Equal(0, len(p.Targets))
+}
+
+type testGeoResolver struct{}
+
+func (testGeoResolver) Resolve(ip net.IP) (*GeoInfo, error) {
+	return &GeoInfo{
+		Country: "AU",
+		City:    "Sydney",
+		ASN:     13335,
+		ASOrg:   "Cloudflare",
+	}, nil
+}
+
+func TestResolveGeo(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	c.SetGeoResolver(testGeoResolver{})
+	p := &Proxy{
+		IP:     "127.0.0.1",
+		ExitIP: "1.1.1.1",
+	}
+	p.publish(&detectResult{
+		geo: c.resolveGeo(p.exitKey()),
+	})
+	assert.Equal("AU", p.Country)
+	assert.Equal("Sydney", p.City)
+	assert.Equal(uint(13335), p.ASN)
+	assert.Equal("Cloudflare", p.ASOrg)
+
+	assert.True(NewCountryFilter("au")(p))
+	assert.False(NewCountryFilter("cn")(p))
+	assert.True(NewASNFilter(13335)(p))
+	assert.False(NewASNFilter(4134)(p))
+}
+
+func TestAnalyzeWhileFinding(t *testing.T) {
+	assert := assert.New(t)
+	server, p := newTestHTTPProxy(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	defer server.Close()
+
+	originalTargets := detectConfig.Targets
+	defer func() {
+		detectConfig.Targets = originalTargets
+	}()
+	detectConfig.Targets = []config.DetectTarget{
+		{
+			Name: "a",
+			URL:  "http://a.com/",
+		},
+	}
+	c := new(Crawler)
+	c.SetGeoResolver(testGeoResolver{})
+	c.avaliableProxyList.Add(p)
+
+	// 检测的同时获取与序列化代理（go test -race）
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			c.analyze(p)
+		}
+	}()
+	filters := []ProxyFilter{
+		NewTargetFilter("a"),
+		NewAnonymityFilter(AnonymityTransparent),
+		NewCountryFilter("AU"),
+	}
+	for i := 0; i < 100; i++ {
+		c.FindAvailableProxy(FindOptions{
+			Speed:        -1,
+			DistinctExit: true,
+		}, filters...)
+		_, err := json.Marshal(c.GetAvailableProxyList())
+		assert.Nil(err)
+		_ = p.Redact()
+	}
+	wg.Wait()
+	assert.Equal([]string{"a"}, p.getTargets())
+	assert.Equal("AU", p.getGeo().Country)
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 超过此时间未更新的记录则清除
+	domainHealthTTL = 24 * time.Hour
+)
+
+type (
+	// DomainHealth health of the proxy for the domain
+	DomainHealth struct {
+		Successes int64 `json:"successes"`
+		Failures  int64 `json:"failures"`
+		// 最近一次失败的时间，成功后重置
+		FailedAt int64 `json:"failedAt,omitempty"`
+		// 最近一次失败是否被目标网站禁止
+		Blocked   bool  `json:"blocked,omitempty"`
+		UpdatedAt int64 `json:"updatedAt"`
+	}
+	// domainHealthTable health table of (proxy, domain)
+	domainHealthTable struct {
+		sync.RWMutex
+		data map[string]*DomainHealth
+	}
+)
+
+// normalizeDomain get the lower case domain without port
+func normalizeDomain(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// domainHealthKey get the key of proxy and domain
+func domainHealthKey(p *Proxy, domain string) string {
+	return p.Key() + "|" + domain
+}
+
+// record record the outcome of proxy for the domain
+func (t *domainHealthTable) record(p *Proxy, domain, outcome string) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return
+	}
+	key := domainHealthKey(p, domain)
+	now := time.Now().Unix()
+	t.Lock()
+	defer t.Unlock()
+	if t.data == nil {
+		t.data = make(map[string]*DomainHealth)
+	}
+	health := t.data[key]
+	if health == nil {
+		health = new(DomainHealth)
+		t.data[key] = health
+	}
+	health.UpdatedAt = now
+	if outcome == OutcomeSuccess {
+		health.Successes++
+		health.FailedAt = 0
+		health.Blocked = false
+		return
+	}
+	health.Failures++
+	health.FailedAt = now
+	health.Blocked = outcome == OutcomeBlocked
+}
+
+// get get the health of proxy for the domain
+func (t *domainHealthTable) get(p *Proxy, domain string) *DomainHealth {
+	t.RLock()
+	defer t.RUnlock()
+	health := t.data[domainHealthKey(p, normalizeDomain(domain))]
+	if health == nil {
+		return nil
+	}
+	cp := *health
+	return &cp
+}
+
+// prune remove the records which aren't updated for a long time
+func (t *domainHealthTable) prune() {
+	expiredAt := time.Now().Add(-domainHealthTTL).Unix()
+	t.Lock()
+	defer t.Unlock()
+	for key, health := range t.data {
+		if health.UpdatedAt < expiredAt {
+			delete(t.data, key)
+		}
+	}
+}
+
+// NewDomainFilter create a filter of domain, the proxy which fails for the domain
+// recently is skipped, the blocked proxy uses a longer cooldown
+func (c *Crawler) NewDomainFilter(domain string) ProxyFilter {
+	domain = normalizeDomain(domain)
+	now := time.Now()
+	return func(p *Proxy) bool {
+		health := c.domainHealth.get(p, domain)
+		if health == nil || health.FailedAt == 0 {
+			return true
+		}
+		cooldown := domainConfig.Cooldown
+		if health.Blocked {
+			cooldown = domainConfig.BlockedCooldown
+		}
+		return now.Sub(time.Unix(health.FailedAt, 0)) >= cooldown
+	}
+}
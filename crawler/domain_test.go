@@ -0,0 +1,71 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("example.com", normalizeDomain("Example.COM:443"))
+	assert.Equal("example.com", normalizeDomain("example.com."))
+}
+
+func TestDomainHealth(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	p := &Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+	}
+	c.avaliableProxyList.Add(p)
+	filter := c.NewDomainFilter("example.com")
+	assert.True(filter(p))
+
+	// 被禁止只影响该网站，不增加全局的失败次数
+	assert.Nil(c.Report(p, OutcomeBlocked, "example.com:443"))
+	assert.Equal(int32(0), p.Fails)
+	health := c.domainHealth.get(p, "example.com")
+	assert.Equal(int64(1), health.Failures)
+	assert.True(health.Blocked)
+	assert.False(c.NewDomainFilter("example.com")(p))
+	assert.True(c.NewDomainFilter("github.com")(p))
+
+	// 冷却时间后可再次使用
+	c.domainHealth.data[domainHealthKey(p, "example.com")].FailedAt = time.Now().Add(-domainConfig.BlockedCooldown).Unix()
+	assert.True(c.NewDomainFilter("example.com")(p))
+
+	// 成功后重置
+	assert.Nil(c.Report(p, OutcomeSuccess, "github.com"))
+	p.detecting = 1
+	assert.Nil(c.Report(p, OutcomeTimeout, "github.com"))
+	assert.False(c.NewDomainFilter("github.com")(p))
+	assert.Nil(c.Report(p, OutcomeSuccess, "github.com"))
+	assert.True(c.NewDomainFilter("github.com")(p))
+	health = c.domainHealth.get(p, "github.com")
+	assert.Equal(int64(2), health.Successes)
+	assert.Equal(int64(1), health.Failures)
+
+	// 长时间未更新的记录被清除
+	c.domainHealth.data[domainHealthKey(p, "github.com")].UpdatedAt = time.Now().Add(-2 * domainHealthTTL).Unix()
+	c.domainHealth.prune()
+	assert.Nil(c.domainHealth.get(p, "github.com"))
+	assert.NotNil(c.domainHealth.get(p, "example.com"))
+}
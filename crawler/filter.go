@@ -0,0 +1,74 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// NewSourceFilter create a filter of source
+func NewSourceFilter(source string) ProxyFilter {
+	return func(p *Proxy) bool {
+		return p.Source == source
+	}
+}
+
+// NewLatencyFilter create a filter of max latency, the average latency is used
+func NewLatencyFilter(maxLatency time.Duration) ProxyFilter {
+	max := maxLatency.Milliseconds()
+	return func(p *Proxy) bool {
+		avg := atomic.LoadInt64(&p.AvgLatency)
+		// 未检测过延时的忽略
+		return avg > 0 && avg <= max
+	}
+}
+
+// NewTargetFilter create a filter of detect target, the proxy should pass the target
+func NewTargetFilter(target string) ProxyFilter {
+	return func(p *Proxy) bool {
+		return containsString(p.getTargets(), target)
+	}
+}
+
+// NewAnonymityFilter create a filter of anonymity, the anonymity of proxy
+// should be equal or higher than the level(transparent < anonymous < elite),
+// no proxy matches the unknown level
+func NewAnonymityFilter(anonymity string) ProxyFilter {
+	level, ok := anonymityLevels[anonymity]
+	if !ok {
+		return func(_ *Proxy) bool {
+			return false
+		}
+	}
+	return func(p *Proxy) bool {
+		return anonymityLevels[p.getAnonymity()] >= level
+	}
+}
+
+// NewCountryFilter create a filter of country(iso code)
+func NewCountryFilter(country string) ProxyFilter {
+	return func(p *Proxy) bool {
+		return strings.EqualFold(p.getGeo().Country, country)
+	}
+}
+
+// NewASNFilter create a filter of asn
+func NewASNFilter(asn uint) ProxyFilter {
+	return func(p *Proxy) bool {
+		return p.getGeo().ASN == asn
+	}
+}
@@ -44,6 +44,7 @@ func NewIP66Proxy(interval time.Duration) *ip66Proxy {
 		Timeout: defaulttProxyTimeout,
 	})
 	ip66 := new(ip66Proxy)
+	ip66.name = ProxyIP66
 	ip66.interval = interval
 	ip66.ins = ins
 	return ip66
@@ -62,7 +63,7 @@ func (ip66 *ip66Proxy) Start() {
 }
 
 func (ip66 *ip66Proxy) fetch() (err error) {
-	doc, err := ip66.fetchPage("ip66", "/%d")
+	doc, err := ip66.fetchPage("/%d")
 	if err != nil || doc == nil {
 		return
 	}
@@ -90,8 +91,7 @@ func (ip66 *ip66Proxy) fetch() (err error) {
 		if ip == "" || port == "" || ip66.fetchListener == nil {
 			return
 		}
-		fn := ip66.fetchListener
-		fn(&Proxy{
+		ip66.emit(&Proxy{
 			IP:        ip,
 			Port:      port,
 			Anonymous: true,
@@ -0,0 +1,260 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vicanso/go-axios"
+	"go.uber.org/zap"
+)
+
+type (
+	// JSONProxyConfig config of json proxy crawler,
+	// the path of field is like: $.data.list[0].ip
+	JSONProxyConfig struct {
+		// Name name of the crawler
+		Name string
+		// BaseURL base url of the api
+		BaseURL string
+		// PageURL url of the api
+		PageURL string
+		// PageParam query param of page number, e.g.: page
+		PageParam string
+		// CursorPath path of the next cursor field
+		CursorPath string
+		// CursorParam query param of the next cursor
+		CursorParam string
+		// ListPath path of the proxy list, empty means the data is the list
+		ListPath string
+		// IPPath path of ip field in the item of list
+		IPPath string
+		// PortPath path of port field in the item of list
+		PortPath string
+		// CategoryPath path of category field in the item of list
+		CategoryPath string
+		// AnonymityPath path of anonymity field in the item of list
+		AnonymityPath string
+		// AnonymityKeyword the proxy is anonymous if the anonymity field contains the keyword
+		AnonymityKeyword string
+		// Category default category of the proxy
+		Category string
+	}
+	// jsonProxy json proxy, crawl the proxy from json api
+	jsonProxy struct {
+		baseProxyCrawler
+		conf   JSONProxyConfig
+		cursor string
+	}
+)
+
+// NewJSONProxy create a new json proxy crawler
+func NewJSONProxy(interval time.Duration, conf JSONProxyConfig) *jsonProxy {
+	header := make(http.Header)
+	header.Set("User-Agent", defaultUserAgent)
+	header.Set("Accept", "application/json")
+	ins := axios.NewInstance(&axios.InstanceConfig{
+		BaseURL: conf.BaseURL,
+		Headers: header,
+		Timeout: defaulttProxyTimeout,
+	})
+	if conf.IPPath == "" {
+		conf.IPPath = "ip"
+	}
+	if conf.PortPath == "" {
+		conf.PortPath = "port"
+	}
+	if conf.AnonymityKeyword == "" {
+		conf.AnonymityKeyword = defaultAnonymityKeyword
+	}
+	if conf.Category == "" {
+		conf.Category = CategoryHTTP
+	}
+	jp := new(jsonProxy)
+	jp.name = conf.Name
+	jp.interval = interval
+	jp.ins = ins
+	jp.conf = conf
+	return jp
+}
+
+// Start start the crawler
+func (jp *jsonProxy) Start() {
+	jp.status = StatusRunning
+	for {
+		if jp.status != StatusRunning {
+			return
+		}
+		_ = jp.fetch()
+		time.Sleep(jp.interval)
+	}
+}
+
+// appendQuery append the query to url
+func appendQuery(rawURL, key, value string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + url.QueryEscape(key) + "=" + value
+}
+
+// urlTemplate get the url template of the current page
+func (jp *jsonProxy) urlTemplate() string {
+	conf := jp.conf
+	switch {
+	case conf.CursorParam != "":
+		if jp.cursor == "" {
+			return conf.PageURL
+		}
+		// 转义后的游标只包含大写的十六进制，不会被当作分页模板
+		return appendQuery(conf.PageURL, conf.CursorParam, url.QueryEscape(jp.cursor))
+	case conf.PageParam != "":
+		return appendQuery(conf.PageURL, conf.PageParam, "%d")
+	default:
+		return conf.PageURL
+	}
+}
+
+// fetch fetch proxy list from json api
+func (jp *jsonProxy) fetch() (err error) {
+	conf := jp.conf
+	// 使用分页参数时，如果有限制最大页数，则按最大页数循环
+	if jp.maxPage == 0 && jp.limitMaxPage != 0 && conf.PageParam != "" {
+		jp.maxPage = jp.limitMaxPage
+	}
+	data, err := jp.fetchData(jp.urlTemplate())
+	if err != nil || len(data) == 0 {
+		return
+	}
+	var result interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&result)
+	if err != nil {
+		logger.Error("decode proxy list fail",
+			zap.String("name", conf.Name),
+			zap.Error(err),
+		)
+		return
+	}
+	list, _ := getJSONValue(result, conf.ListPath).([]interface{})
+	for _, item := range list {
+		ip := jsonString(getJSONValue(item, conf.IPPath))
+		port := jsonString(getJSONValue(item, conf.PortPath))
+		category := conf.Category
+		if conf.CategoryPath != "" {
+			category = strings.ToLower(jsonString(getJSONValue(item, conf.CategoryPath)))
+		}
+		anonymous := false
+		if conf.AnonymityPath != "" {
+			value := getJSONValue(item, conf.AnonymityPath)
+			if v, ok := value.(bool); ok {
+				anonymous = v
+			} else {
+				anonymous = strings.Contains(jsonString(value), conf.AnonymityKeyword)
+			}
+		}
+		if ip == "" ||
+			port == "" ||
+			category == "" ||
+			jp.fetchListener == nil {
+			continue
+		}
+		jp.emit(&Proxy{
+			IP:        ip,
+			Port:      port,
+			Anonymous: anonymous,
+			Category:  category,
+		})
+	}
+
+	switch {
+	case conf.CursorParam != "":
+		jp.cursor = jsonString(getJSONValue(result, conf.CursorPath))
+		// 无下一页或者超过限制的页数，则从头开始
+		if jp.cursor == "" || (jp.limitMaxPage != 0 && jp.currentPage >= jp.limitMaxPage) {
+			jp.cursor = ""
+			jp.currentPage = 0
+		}
+	case conf.PageParam != "":
+		// 当前页无数据，则从第一页开始
+		if len(list) == 0 {
+			jp.currentPage = 0
+			jp.maxPage = 0
+		}
+	}
+	return
+}
+
+// getJSONValue get the value of path, e.g.: $.data.list[0].ip
+func getJSONValue(data interface{}, path string) interface{} {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return data
+	}
+	current := data
+	for _, field := range strings.Split(path, ".") {
+		// 获取字段名与数组下标，如：list[0][1]
+		indexes := make([]int, 0)
+		if i := strings.Index(field, "["); i != -1 {
+			for _, item := range strings.Split(field[i+1:], "[") {
+				index, err := strconv.Atoi(strings.TrimSuffix(item, "]"))
+				if err != nil {
+					return nil
+				}
+				indexes = append(indexes, index)
+			}
+			field = field[:i]
+		}
+		if field != "" {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = m[field]
+		}
+		for _, index := range indexes {
+			arr, ok := current.([]interface{})
+			if !ok || index < 0 || index >= len(arr) {
+				return nil
+			}
+			current = arr[index]
+		}
+	}
+	return current
+}
+
+// jsonString convert the json value to string
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
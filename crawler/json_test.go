@@ -0,0 +1,114 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/go-axios"
+)
+
+func TestGetJSONValue(t *testing.T) {
+	assert := assert.New(t)
+	var data interface{}
+	err := json.Unmarshal([]byte(`{
+		"data": {
+			"list": [{"ip": "1.1.1.1"}, {"ip": "2.2.2.2", "ports": [80, 8080]}]
+		}
+	}`), &data)
+	assert.Nil(err)
+	assert.Equal("2.2.2.2", getJSONValue(data, "$.data.list[1].ip"))
+	assert.Equal("8080", jsonString(getJSONValue(data, "data.list[1].ports[1]")))
+	assert.Nil(getJSONValue(data, "data.list[2].ip"))
+	assert.Nil(getJSONValue(data, "data.list.ip"))
+	assert.Equal(data, getJSONValue(data, "$"))
+}
+
+func TestJSONProxyPageParam(t *testing.T) {
+	assert := assert.New(t)
+	jp := NewJSONProxy(time.Minute, JSONProxyConfig{
+		Name:             "test",
+		BaseURL:          "http://127.0.0.1",
+		PageURL:          "/api?format=json",
+		PageParam:        "page",
+		ListPath:         "$.data.list",
+		CategoryPath:     "type",
+		AnonymityPath:    "anonymity",
+		AnonymityKeyword: "elite",
+	})
+	assert.Equal("/api?format=json&page=%d", jp.urlTemplate())
+	jp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(`{"data":{"list":[{"ip":"1.1.1.1","port":8080,"type":"HTTPS","anonymity":"elite"},{"ip":"","port":80}]}}`),
+	})
+	done := make(chan bool)
+	jp.OnFetch(func(p *Proxy) {
+		assert.Equal("1.1.1.1", p.IP)
+		assert.Equal("8080", p.Port)
+		assert.Equal("https", p.Category)
+		assert.True(p.Anonymous)
+		done <- true
+	})
+	go jp.Start()
+	<-done
+	jp.Stop()
+
+	// 无数据时从第一页重新开始
+	jp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(`{"data":{"list":[]}}`),
+	})
+	assert.Nil(jp.fetch())
+	assert.Equal(0, jp.currentPage)
+}
+
+func TestJSONProxyCursor(t *testing.T) {
+	assert := assert.New(t)
+	jp := NewJSONProxy(time.Minute, JSONProxyConfig{
+		Name:        "test",
+		BaseURL:     "http://127.0.0.1",
+		PageURL:     "/api",
+		CursorPath:  "next",
+		CursorParam: "cursor",
+		ListPath:    "items",
+		IPPath:      "host",
+	})
+	list := make([]*Proxy, 0)
+	jp.OnFetch(func(p *Proxy) {
+		list = append(list, p)
+	})
+	assert.Equal("/api", jp.urlTemplate())
+	jp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(`{"items":[{"host":"1.1.1.1","port":"80"}],"next":"a/1"}`),
+	})
+	assert.Nil(jp.fetch())
+	assert.Equal(1, len(list))
+	assert.Equal("http", list[0].Category)
+	assert.Equal("/api?cursor=a%2F1", jp.urlTemplate())
+
+	// 无下一页则重新开始
+	jp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(`{"items":[{"host":"2.2.2.2","port":"80"}]}`),
+	})
+	assert.Nil(jp.fetch())
+	assert.Equal(2, len(list))
+	assert.Equal("/api", jp.urlTemplate())
+	assert.Equal(0, jp.currentPage)
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vicanso/go-axios"
+)
+
+const (
+	// AnonymityTransparent the real ip is leaked to the target
+	AnonymityTransparent = "transparent"
+	// AnonymityAnonymous the real ip is hidden, but the target knows the request is from a proxy
+	AnonymityAnonymous = "anonymous"
+	// AnonymityElite the target doesn't know the request is from a proxy
+	AnonymityElite = "elite"
+)
+
+type (
+	// JudgeResult the request info received by judge
+	JudgeResult struct {
+		// IP source ip of the request
+		IP string `json:"ip"`
+		// Proto protocol of the request, e.g.: HTTP/1.1
+		Proto string `json:"proto,omitempty"`
+		// Method method of the request
+		Method string `json:"method,omitempty"`
+		// Headers headers of the request
+		Headers http.Header `json:"headers"`
+		// TLS tls info of the request, it's nil if the request isn't https
+		TLS *JudgeTLS `json:"tls,omitempty"`
+	}
+	// JudgeTLS tls info of the request
+	JudgeTLS struct {
+		Version            string `json:"version,omitempty"`
+		CipherSuite        string `json:"cipherSuite,omitempty"`
+		ServerName         string `json:"serverName,omitempty"`
+		NegotiatedProtocol string `json:"negotiatedProtocol,omitempty"`
+	}
+)
+
+var (
+	// proxyHeaders headers which reveal the request is from a proxy
+	proxyHeaders = []string{
+		"Via",
+		"Forwarded",
+		"X-Forwarded-For",
+		"X-Forwarded-Host",
+		"X-Real-Ip",
+		"X-Client-Ip",
+		"Client-Ip",
+		"X-Proxy-Id",
+		"Proxy-Connection",
+	}
+	anonymityLevels = map[string]int{
+		AnonymityTransparent: 1,
+		AnonymityAnonymous:   2,
+		AnonymityElite:       3,
+	}
+
+	tlsVersions = map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS11: "TLS 1.1",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+	}
+
+	errJudgeIPEmpty = errors.New("ip of judge result is empty")
+)
+
+type (
+	// realIPCall the request of getting real ip in flight
+	realIPCall struct {
+		done chan struct{}
+		ip   string
+		err  error
+	}
+)
+
+var (
+	// 当前实例的真实IP，用于判断代理是否透明，每个检测周期更新一次
+	realIPMutex     = sync.Mutex{}
+	realIP          string
+	realIPExpiredAt time.Time
+	realIPInflight  *realIPCall
+)
+
+// NewJudgeResult create a judge result of the request,
+// the ip is the remote address of connection, the forwarded headers aren't trusted
+func NewJudgeResult(req *http.Request) *JudgeResult {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	header := req.Header.Clone()
+	// Host不在header中，添加方便排查
+	if req.Host != "" {
+		header.Set("Host", req.Host)
+	}
+	result := &JudgeResult{
+		IP:      ip,
+		Proto:   req.Proto,
+		Method:  req.Method,
+		Headers: header,
+	}
+	if req.TLS != nil {
+		version := tlsVersions[req.TLS.Version]
+		if version == "" {
+			version = fmt.Sprintf("0x%04x", req.TLS.Version)
+		}
+		result.TLS = &JudgeTLS{
+			Version:            version,
+			CipherSuite:        tls.CipherSuiteName(req.TLS.CipherSuite),
+			ServerName:         req.TLS.ServerName,
+			NegotiatedProtocol: req.TLS.NegotiatedProtocol,
+		}
+	}
+	return result
+}
+
+// judge request the judge url and get the result
+func judge(ins *axios.Instance, url string) (result *JudgeResult, err error) {
+	resp, err := ins.Get(url)
+	if err != nil {
+		return
+	}
+	if resp.Status != http.StatusOK {
+		err = errUnexpectedStatus
+		return
+	}
+	result = new(JudgeResult)
+	err = json.Unmarshal(resp.Data, result)
+	if err != nil {
+		return
+	}
+	if result.IP == "" {
+		err = errJudgeIPEmpty
+		return
+	}
+	return
+}
+
+// getRealIP get the real ip of the current instance from judge, it's cached for the detect interval,
+// the concurrent calls share one request, and the expired ip is used if the request fails
+func getRealIP(url string) (string, error) {
+	realIPMutex.Lock()
+	if realIP != "" && time.Now().Before(realIPExpiredAt) {
+		ip := realIP
+		realIPMutex.Unlock()
+		return ip, nil
+	}
+	// 已有请求在进行中，等待其结果
+	if call := realIPInflight; call != nil {
+		realIPMutex.Unlock()
+		<-call.done
+		return call.ip, call.err
+	}
+	call := &realIPCall{
+		done: make(chan struct{}),
+	}
+	realIPInflight = call
+	realIPMutex.Unlock()
+
+	// 请求在锁外执行，避免阻塞其它检测
+	ins := axios.NewInstance(&axios.InstanceConfig{
+		Timeout: detectConfig.Timeout,
+	})
+	result, err := judge(ins, url)
+
+	realIPMutex.Lock()
+	if err == nil {
+		realIP = result.IP
+		realIPExpiredAt = time.Now().Add(detectConfig.Interval)
+	}
+	// 获取失败时使用过期的IP（如果有）
+	if realIP != "" {
+		call.ip = realIP
+	} else {
+		call.err = err
+	}
+	realIPInflight = nil
+	realIPMutex.Unlock()
+	close(call.done)
+	return call.ip, call.err
+}
+
+// containsIP test whether or not the header value contains the ip, the value is split
+// by comma, semicolon and space, and each part is compared as a whole ip, e.g.:
+// "1.1.1.1, 2.2.2.2", "for=1.1.1.1;proto=http" or "for=\"[2001:db8::1]:80\""
+func containsIP(value, ip string) bool {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return false
+	}
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+	for _, field := range fields {
+		// Forwarded的格式为key=value
+		if index := strings.LastIndex(field, "="); index != -1 {
+			field = field[index+1:]
+		}
+		field = strings.Trim(field, `"`)
+		if host, _, err := net.SplitHostPort(field); err == nil {
+			field = host
+		}
+		field = strings.Trim(field, "[]")
+		if target.Equal(net.ParseIP(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidAnonymity test whether or not the anonymity is supported
+func IsValidAnonymity(anonymity string) bool {
+	_, ok := anonymityLevels[anonymity]
+	return ok
+}
+
+// classifyAnonymity get the anonymity of proxy from the judge result
+func classifyAnonymity(result *JudgeResult, realIP string) string {
+	if result.IP == realIP {
+		return AnonymityTransparent
+	}
+	revealed := false
+	for _, key := range proxyHeaders {
+		values := result.Headers.Values(key)
+		if len(values) == 0 {
+			continue
+		}
+		revealed = true
+		for _, value := range values {
+			if containsIP(value, realIP) {
+				return AnonymityTransparent
+			}
+		}
+	}
+	if revealed {
+		return AnonymityAnonymous
+	}
+	return AnonymityElite
+}
+
+// verifyAnonymity verify the anonymity and get the exit ip of proxy with judge
+func verifyAnonymity(ins *axios.Instance, url string) (exitIP, anonymity string, err error) {
+	ip, err := getRealIP(url)
+	if err != nil {
+		return
+	}
+	result, err := judge(ins, url)
+	if err != nil {
+		return
+	}
+	return result.IP, classifyAnonymity(result, ip), nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/proxy-pool/config"
+)
+
+func TestClassifyAnonymity(t *testing.T) {
+	assert := assert.New(t)
+	newHeader := func(key, value string) http.Header {
+		h := make(http.Header)
+		if key != "" {
+			h.Set(key, value)
+		}
+		return h
+	}
+	tests := []struct {
+		result    *JudgeResult
+		anonymity string
+	}{
+		{
+			result: &JudgeResult{
+				IP:      "1.1.1.1",
+				Headers: newHeader("", ""),
+			},
+			anonymity: AnonymityTransparent,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("X-Forwarded-For", "1.1.1.1"),
+			},
+			anonymity: AnonymityTransparent,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("Via", "1.1 squid"),
+			},
+			anonymity: AnonymityAnonymous,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("X-Forwarded-For", "11.1.1.10, 3.3.3.3"),
+			},
+			anonymity: AnonymityAnonymous,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("X-Forwarded-For", "3.3.3.3,1.1.1.1"),
+			},
+			anonymity: AnonymityTransparent,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("Forwarded", `for="1.1.1.1:8080";proto=http`),
+			},
+			anonymity: AnonymityTransparent,
+		},
+		{
+			result: &JudgeResult{
+				IP:      "2.2.2.2",
+				Headers: newHeader("User-Agent", "go"),
+			},
+			anonymity: AnonymityElite,
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.anonymity, classifyAnonymity(tt.result, "1.1.1.1"))
+	}
+}
+
+func TestVerifyAnonymity(t *testing.T) {
+	assert := assert.New(t)
+	server, p := newTestHTTPProxy(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "judge.com" {
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		header := make(http.Header)
+		header.Set("Via", "1.1 test")
+		buf, _ := json.Marshal(&JudgeResult{
+			IP:      "2.2.2.2",
+			Headers: header,
+		})
+		_, _ = w.Write(buf)
+	})
+	defer server.Close()
+
+	originalTargets := detectConfig.Targets
+	originalJudge := detectConfig.Judge
+	defer func() {
+		detectConfig.Targets = originalTargets
+		detectConfig.Judge = originalJudge
+		realIP = ""
+		realIPExpiredAt = time.Time{}
+	}()
+	detectConfig.Targets = []config.DetectTarget{
+		{
+			Name: "a",
+			URL:  "http://a.com/",
+		},
+	}
+	detectConfig.Judge = "http://judge.com/judge"
+	realIP = "1.1.1.1"
+	realIPExpiredAt = time.Now().Add(time.Minute)
+
+	c := new(Crawler)
+	assert.True(c.analyze(p))
+	assert.Equal(AnonymityAnonymous, p.Anonymity)
+	assert.Equal("2.2.2.2", p.ExitIP)
+	assert.True(p.Anonymous)
+	assert.True(NewAnonymityFilter(AnonymityAnonymous)(p))
+	assert.False(NewAnonymityFilter(AnonymityElite)(p))
+	assert.False(NewAnonymityFilter(AnonymityTransparent)(&Proxy{}))
+	// 未知的匿名度不匹配任何代理
+	assert.False(IsValidAnonymity("elit"))
+	assert.False(NewAnonymityFilter("elit")(p))
+}
+
+func TestGetRealIP(t *testing.T) {
+	assert := assert.New(t)
+	var count int32
+	var failed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failed) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(&count, 1)
+		time.Sleep(50 * time.Millisecond)
+		buf, _ := json.Marshal(NewJudgeResult(r))
+		_, _ = w.Write(buf)
+	}))
+	defer server.Close()
+
+	originalInterval := detectConfig.Interval
+	defer func() {
+		detectConfig.Interval = originalInterval
+		realIP = ""
+		realIPExpiredAt = time.Time{}
+	}()
+	detectConfig.Interval = time.Minute
+
+	// 并发获取只请求一次
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ip, err := getRealIP(server.URL)
+			assert.Nil(err)
+			assert.Equal("127.0.0.1", ip)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&count))
+
+	// 过期后重新获取
+	realIPMutex.Lock()
+	realIPExpiredAt = time.Now()
+	realIPMutex.Unlock()
+	ip, err := getRealIP(server.URL)
+	assert.Nil(err)
+	assert.Equal("127.0.0.1", ip)
+	assert.Equal(int32(2), atomic.LoadInt32(&count))
+
+	// 获取失败时使用过期的IP
+	atomic.StoreInt32(&failed, 1)
+	realIPMutex.Lock()
+	realIPExpiredAt = time.Now()
+	realIPMutex.Unlock()
+	ip, err = getRealIP(server.URL)
+	assert.Nil(err)
+	assert.Equal("127.0.0.1", ip)
+
+	realIPMutex.Lock()
+	realIP = ""
+	realIPMutex.Unlock()
+	_, err = getRealIP(server.URL)
+	assert.NotNil(err)
+}
+
+func TestNewJudgeResult(t *testing.T) {
+	assert := assert.New(t)
+	req := httptest.NewRequest("GET", "https://judge.com/judge", nil)
+	req.Header.Set("Via", "1.1 test")
+	req.Header.Set("X-Forwarded-For", "3.3.3.3")
+	req.TLS = &tls.ConnectionState{
+		Version:     tls.VersionTLS12,
+		CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		ServerName:  "judge.com",
+	}
+	result := NewJudgeResult(req)
+	// 使用连接的IP，不信任X-Forwarded-For
+	assert.Equal("192.0.2.1", result.IP)
+	assert.Equal("HTTP/1.1", result.Proto)
+	assert.Equal("GET", result.Method)
+	assert.Equal("1.1 test", result.Headers.Get("Via"))
+	assert.Equal("judge.com", result.Headers.Get("Host"))
+	assert.Equal("TLS 1.2", result.TLS.Version)
+	assert.Equal("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", result.TLS.CipherSuite)
+	assert.Equal("judge.com", result.TLS.ServerName)
+
+	req = httptest.NewRequest("GET", "http://judge.com/judge", nil)
+	assert.Nil(NewJudgeResult(req).TLS)
+}
+
+func TestContainsIP(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(containsIP("1.2.3.4", "1.2.3.4"))
+	assert.True(containsIP("5.5.5.5, 1.2.3.4", "1.2.3.4"))
+	assert.True(containsIP("1.2.3.4:80", "1.2.3.4"))
+	assert.True(containsIP(`for="[2001:db8::1]:80"`, "2001:db8::1"))
+	assert.True(containsIP("for=2001:db8::1", "2001:db8::1"))
+	assert.False(containsIP("11.2.3.45", "1.2.3.4"))
+	assert.False(containsIP("1.1 squid", "1.2.3.4"))
+	assert.False(containsIP("1.2.3.4", ""))
+}
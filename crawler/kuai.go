@@ -46,6 +46,7 @@ func NewKuaiProxy(interval time.Duration) *kuaiProxy {
 		Timeout: defaulttProxyTimeout,
 	})
 	kuaiProxy := new(kuaiProxy)
+	kuaiProxy.name = ProxyKuai
 	kuaiProxy.interval = interval
 	kuaiProxy.ins = ins
 	return kuaiProxy
@@ -66,7 +67,7 @@ func (kuai *kuaiProxy) Start() {
 
 // Fetch fetch proxy list from kuai dai li
 func (kuai *kuaiProxy) fetch() (err error) {
-	doc, err := kuai.fetchPage("kuai daili", "/%d/")
+	doc, err := kuai.fetchPage("/%d/")
 	if err != nil || doc == nil {
 		return
 	}
@@ -93,7 +94,7 @@ func (kuai *kuaiProxy) fetch() (err error) {
 			kuai.fetchListener == nil {
 			return
 		}
-		kuai.fetchListener(&Proxy{
+		kuai.emit(&Proxy{
 			IP:        ip,
 			Port:      port,
 			Anonymous: true,
@@ -0,0 +1,148 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// 清除过期租用的间隔
+	leaseCleanInterval = time.Minute
+)
+
+var (
+	// ErrLeaseNotFound the lease doesn't exist or is expired
+	ErrLeaseNotFound = errors.New("lease not found")
+	// ErrLeaseUnsupported the lease is kept in memory of each instance,
+	// so it isn't supported with the store shared by several instances
+	ErrLeaseUnsupported = errors.New("lease isn't supported with the shared store")
+)
+
+type (
+	// Lease the proxy checked out exclusively until released or expired
+	Lease struct {
+		ID        string    `json:"id,omitempty"`
+		Proxy     *Proxy    `json:"proxy,omitempty"`
+		ExpiredAt time.Time `json:"expiredAt,omitempty"`
+	}
+	// leaseTable lease table, the expired leases are removed automatically
+	leaseTable struct {
+		sync.Mutex
+		once sync.Once
+		data map[string]*Lease
+	}
+)
+
+// newLeaseID generate a random id of lease
+func newLeaseID() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// clean remove the expired leases and return them
+func (t *leaseTable) clean() []*Lease {
+	now := time.Now()
+	t.Lock()
+	defer t.Unlock()
+	list := make([]*Lease, 0)
+	for id, l := range t.data {
+		if now.After(l.ExpiredAt) {
+			delete(t.data, id)
+			list = append(list, l)
+		}
+	}
+	return list
+}
+
+// init init the data and start the cleaner
+func (t *leaseTable) init(pl *ProxyList) {
+	t.once.Do(func() {
+		go func() {
+			for range time.NewTicker(leaseCleanInterval).C {
+				pl.unlease(t.clean()...)
+			}
+		}()
+	})
+	if t.data == nil {
+		t.data = make(map[string]*Lease)
+	}
+}
+
+// LeaseProxy check out one proxy exclusively for the ttl, the leased proxy
+// isn't returned by other finds until it's released or the lease expires.
+// The lease is kept in memory, so it's unsupported with the shared store
+func (c *Crawler) LeaseProxy(ttl time.Duration, opts FindOptions, filters ...ProxyFilter) (*Lease, error) {
+	// 其它实例不知道租用信息，仍会返回该代理
+	if c.avaliableProxyList.Shared() {
+		return nil, ErrLeaseUnsupported
+	}
+	id, err := newLeaseID()
+	if err != nil {
+		return nil, err
+	}
+	t := &c.leases
+	t.Lock()
+	defer t.Unlock()
+	t.init(&c.avaliableProxyList)
+	expiredAt := time.Now().Add(ttl)
+	p := c.avaliableProxyList.lease(expiredAt, opts, filters...)
+	if p == nil {
+		return nil, nil
+	}
+	l := &Lease{
+		ID:        id,
+		Proxy:     p,
+		ExpiredAt: expiredAt,
+	}
+	t.data[id] = l
+	return l, nil
+}
+
+// ReleaseProxy release the leased proxy, the outcome of the usage is reported if it isn't empty
+func (c *Crawler) ReleaseProxy(id, outcome, host string) error {
+	if outcome != "" && !IsValidOutcome(outcome) {
+		return ErrInvalidOutcome
+	}
+	t := &c.leases
+	t.Lock()
+	l := t.data[id]
+	delete(t.data, id)
+	t.Unlock()
+	if l == nil {
+		return ErrLeaseNotFound
+	}
+	c.avaliableProxyList.unlease(l)
+	if time.Now().After(l.ExpiredAt) {
+		return ErrLeaseNotFound
+	}
+	if outcome == "" {
+		return nil
+	}
+	err := c.Report(l.Proxy, outcome, host)
+	// 代理在租用期间已被剔除，租用仍正常释放
+	if err == ErrProxyNotFound {
+		return nil
+	}
+	return err
+}
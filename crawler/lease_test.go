@@ -0,0 +1,122 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseProxy(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	for i := 1; i <= 5; i++ {
+		c.avaliableProxyList.Add(&Proxy{
+			IP:       "127.0.0." + strconv.Itoa(i),
+			Port:     "80",
+			Category: "http",
+		})
+	}
+	opts := FindOptions{
+		Speed: -1,
+	}
+
+	// 并发租用，每个代理只能被租用一次
+	leases := make(chan *Lease, 10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := c.LeaseProxy(time.Minute, opts)
+			assert.Nil(err)
+			if l != nil {
+				leases <- l
+			}
+		}()
+	}
+	wg.Wait()
+	close(leases)
+	keys := make(map[string]bool)
+	var first *Lease
+	for l := range leases {
+		assert.NotEmpty(l.ID)
+		assert.False(keys[l.Proxy.Key()])
+		keys[l.Proxy.Key()] = true
+		first = l
+	}
+	assert.Equal(5, len(keys))
+	assert.Nil(c.FindAvailableProxy(opts))
+
+	// 释放后可再次获取
+	assert.Equal(ErrInvalidOutcome, c.ReleaseProxy(first.ID, "unknown", ""))
+	assert.Nil(c.ReleaseProxy(first.ID, OutcomeSuccess, ""))
+	assert.Equal(ErrLeaseNotFound, c.ReleaseProxy(first.ID, "", ""))
+	assert.Equal(first.Proxy, c.FindAvailableProxy(opts))
+
+	// 过期后可再次租用，过期的租用不影响新的租用
+	c = new(Crawler)
+	c.avaliableProxyList.Add(&Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+	})
+	expired, err := c.LeaseProxy(time.Millisecond, opts)
+	assert.Nil(err)
+	assert.NotNil(expired)
+	time.Sleep(2 * time.Millisecond)
+	l, err := c.LeaseProxy(time.Minute, opts)
+	assert.Nil(err)
+	assert.NotNil(l)
+	assert.Equal(ErrLeaseNotFound, c.ReleaseProxy(expired.ID, "", ""))
+	c.avaliableProxyList.unlease(c.leases.clean()...)
+	assert.Nil(c.FindAvailableProxy(opts))
+	assert.Nil(c.FindSessionProxy("a", time.Minute, opts))
+	assert.Nil(c.ReleaseProxy(l.ID, "", ""))
+	assert.NotNil(c.FindAvailableProxy(opts))
+}
+
+// testLockerStore shared store for test
+type testLockerStore struct {
+	testStore
+}
+
+func (s *testLockerStore) Lock(name string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (s *testLockerStore) Extend(name string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+func TestLeaseSharedStore(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	assert.Nil(c.SetStore(new(testLockerStore)))
+	c.avaliableProxyList.Add(&Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+	})
+	l, err := c.LeaseProxy(time.Minute, FindOptions{
+		Speed: -1,
+	})
+	assert.Nil(l)
+	assert.Equal(ErrLeaseUnsupported, err)
+}
@@ -15,9 +15,34 @@
 package crawler
 
 import (
-	"math/rand"
+	"encoding/json"
+	"net"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CategoryHTTP http proxy
+	CategoryHTTP = "http"
+	// CategoryHTTPS https proxy, support CONNECT
+	CategoryHTTPS = "https"
+	// CategorySOCKS4 socks4 proxy
+	CategorySOCKS4 = "socks4"
+	// CategorySOCKS5 socks5 proxy
+	CategorySOCKS5 = "socks5"
+
+	// 批量保存代理的间隔
+	flushInterval = 5 * time.Second
+)
+
+var (
+	// 检测结果（通过的目标、匿名度、出口IP与地理信息）的读写锁，
+	// 检测时更新，获取代理时的过滤与序列化读取
+	detectResultMutex sync.RWMutex
 )
 
 type (
@@ -30,14 +55,281 @@ type (
 		Anonymous  bool   `json:"anonymous,omitempty"`
 		Speed      int32  `json:"speed,omitempty"`
 		Fails      int32  `json:"fails,omitempty"`
+		// 累计检测成功与失败的次数
+		Successes int64 `json:"successes,omitempty"`
+		Failures  int64 `json:"failures,omitempty"`
+		// 最近一次被选择的时间（纳秒）
+		SelectedAt int64 `json:"selectedAt,omitempty"`
+		// 检测得到的匿名度：transparent、anonymous、elite
+		Anonymity string `json:"anonymity,omitempty"`
+		// 目标网站看到的出口IP（由judge检测得到）
+		ExitIP string `json:"exitIP,omitempty"`
+		// 出口IP所在的国家（ISO代码）、城市以及ASN
+		Country string `json:"country,omitempty"`
+		City    string `json:"city,omitempty"`
+		ASN     uint   `json:"asn,omitempty"`
+		ASOrg   string `json:"asOrg,omitempty"`
+		// 最近一次检测的延时以及最近多次检测的平均延时（毫秒）
+		Latency    int64 `json:"latency,omitempty"`
+		AvgLatency int64 `json:"avgLatency,omitempty"`
+		// 最近一次检测通过的目标
+		Targets []string `json:"targets,omitempty"`
+		// 代理的来源，抓取的网站名称或者种子代理（seed）
+		Source string `json:"source,omitempty"`
+		// 首次与最近一次抓取到的时间
+		FirstSeenAt int64 `json:"firstSeenAt,omitempty"`
+		LastSeenAt  int64 `json:"lastSeenAt,omitempty"`
+		// 需要认证的代理的账号与密码
+		Username string `json:"username,omitempty"`
+		Password string `json:"password,omitempty"`
+
+		// 最近多次检测的延时，只在检测时使用
+		latencies []int64
+		// 是否正在检测
+		detecting int32
+	}
+	// ProxyFilter proxy filter, return false if the proxy should be skipped
+	ProxyFilter func(*Proxy) bool
+	// ProxyStore proxy store, persist the proxy list
+	ProxyStore interface {
+		// Load load all proxies from store
+		Load() ([]*Proxy, error)
+		// Save save the proxies to store
+		Save(list ...*Proxy) error
+		// Remove remove the proxies from store
+		Remove(list ...*Proxy) error
+	}
+	// ProxyLocker proxy locker, the store shared by several instances should implement it,
+	// so only one instance does the work at a time
+	ProxyLocker interface {
+		// Lock get the lock of name, it will be released after ttl
+		Lock(name string, ttl time.Duration) (bool, error)
+		// Extend extend the ttl of the lock held by the locker
+		Extend(name string, ttl time.Duration) (bool, error)
+	}
+	// GeoInfo geo info of ip
+	GeoInfo struct {
+		Country string
+		City    string
+		ASN     uint
+		ASOrg   string
+	}
+	// detectResult the result of detection, it's published to proxy after detection
+	detectResult struct {
+		targets   []string
+		exitIP    string
+		anonymity string
+		geo       *GeoInfo
+	}
+	// GeoResolver geo resolver, get the geo info of ip
+	GeoResolver interface {
+		// Resolve get the geo info of ip
+		Resolve(ip net.IP) (*GeoInfo, error)
+	}
+	// FindOptions options of finding proxy
+	FindOptions struct {
+		// Category category of proxy, empty means any category
+		Category string
+		// Categories categories of proxy in order of preference, it's used if category is empty
+		Categories []string
+		// Speed speed of proxy, -1 means any speed
+		Speed int32
+		// DistinctExit prefer the proxy whose exit ip is least recently returned
+		DistinctExit bool
+		// Strategy strategy of selection, random is used if it's empty
+		Strategy string
 	}
 	// ProxyList proxy list
 	ProxyList struct {
+		// 轮询选择的游标（放在首位保证64位对齐）
+		cursor uint64
 		sync.RWMutex
-		data []*Proxy
+		data  []*Proxy
+		store ProxyStore
+		// 存储读写的锁，保证保存与删除的顺序与列表一致
+		storeMutex sync.Mutex
+
+		// 各出口IP最近一次被选择的时间
+		exitMutex  sync.Mutex
+		exitUsedAt map[string]int64
+
+		// 被租用代理的到期时间，租用期间不会被选择
+		leasedUntil map[string]int64
+
+		// 等待批量保存的代理
+		dirtyMutex sync.Mutex
+		dirty      map[string]*Proxy
+		flushOnce  sync.Once
 	}
 )
 
+// Addr get the address(ip:port) of proxy
+func (p *Proxy) Addr() string {
+	return net.JoinHostPort(p.IP, p.Port)
+}
+
+// HasAuth test whether or not the proxy needs authentication
+func (p *Proxy) HasAuth() bool {
+	return p.Username != ""
+}
+
+// Redact get a copy of proxy without the username and password
+func (p *Proxy) Redact() *Proxy {
+	cp := p.snapshot()
+	cp.Username = ""
+	cp.Password = ""
+	return cp
+}
+
+// snapshot get a copy of proxy, the fields updated during detection
+// and selection are read with atomic or lock
+func (p *Proxy) snapshot() *Proxy {
+	detectResultMutex.RLock()
+	defer detectResultMutex.RUnlock()
+	return &Proxy{
+		DetectedAt:  atomic.LoadInt64(&p.DetectedAt),
+		IP:          p.IP,
+		Port:        p.Port,
+		Category:    p.Category,
+		Anonymous:   p.Anonymous,
+		Speed:       atomic.LoadInt32(&p.Speed),
+		Fails:       atomic.LoadInt32(&p.Fails),
+		Successes:   atomic.LoadInt64(&p.Successes),
+		Failures:    atomic.LoadInt64(&p.Failures),
+		SelectedAt:  atomic.LoadInt64(&p.SelectedAt),
+		Anonymity:   p.Anonymity,
+		ExitIP:      p.ExitIP,
+		Country:     p.Country,
+		City:        p.City,
+		ASN:         p.ASN,
+		ASOrg:       p.ASOrg,
+		Latency:     atomic.LoadInt64(&p.Latency),
+		AvgLatency:  atomic.LoadInt64(&p.AvgLatency),
+		Targets:     p.Targets,
+		Source:      p.Source,
+		FirstSeenAt: atomic.LoadInt64(&p.FirstSeenAt),
+		LastSeenAt:  atomic.LoadInt64(&p.LastSeenAt),
+		Username:    p.Username,
+		Password:    p.Password,
+	}
+}
+
+// URL get the url of proxy, the username and password are set as user info
+func (p *Proxy) URL(scheme string) *url.URL {
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   p.Addr(),
+	}
+	if p.HasAuth() {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u
+}
+
+// MarshalJSON marshal the snapshot of proxy
+func (p *Proxy) MarshalJSON() ([]byte, error) {
+	type proxy Proxy
+	return json.Marshal((*proxy)(p.snapshot()))
+}
+
+// publish update the detect result of proxy
+func (p *Proxy) publish(r *detectResult) {
+	detectResultMutex.Lock()
+	defer detectResultMutex.Unlock()
+	p.Targets = r.targets
+	// 匿名度检测失败的保留原有结果
+	if r.anonymity != "" {
+		p.ExitIP = r.exitIP
+		p.Anonymity = r.anonymity
+		p.Anonymous = r.anonymity != AnonymityTransparent
+	}
+	if r.geo != nil {
+		p.Country = r.geo.Country
+		p.City = r.geo.City
+		p.ASN = r.geo.ASN
+		p.ASOrg = r.geo.ASOrg
+	}
+}
+
+// getTargets get the targets passed in the latest detection
+func (p *Proxy) getTargets() []string {
+	detectResultMutex.RLock()
+	defer detectResultMutex.RUnlock()
+	return p.Targets
+}
+
+// getAnonymity get the anonymity of proxy
+func (p *Proxy) getAnonymity() string {
+	detectResultMutex.RLock()
+	defer detectResultMutex.RUnlock()
+	return p.Anonymity
+}
+
+// getGeo get the geo info of proxy's exit ip
+func (p *Proxy) getGeo() GeoInfo {
+	detectResultMutex.RLock()
+	defer detectResultMutex.RUnlock()
+	return GeoInfo{
+		Country: p.Country,
+		City:    p.City,
+		ASN:     p.ASN,
+		ASOrg:   p.ASOrg,
+	}
+}
+
+// exitKey get the key of exit, the ip of proxy is used if the exit ip is unknown
+func (p *Proxy) exitKey() string {
+	detectResultMutex.RLock()
+	defer detectResultMutex.RUnlock()
+	if p.ExitIP != "" {
+		return p.ExitIP
+	}
+	return p.IP
+}
+
+// Key get the unique key of proxy
+func (p *Proxy) Key() string {
+	return p.Category + "://" + p.Addr()
+}
+
+// recordLatency record the latency of detection, the average latency
+// of the latest window detections and the speed are updated
+func (p *Proxy) recordLatency(d time.Duration, window int, buckets []time.Duration) {
+	// 最小为1毫秒，0表示未检测
+	latency := d.Milliseconds()
+	if latency <= 0 {
+		latency = 1
+	}
+	// 从存储中加载的代理无历史记录，以其平均延时作为初始值
+	if len(p.latencies) == 0 {
+		avg := atomic.LoadInt64(&p.AvgLatency)
+		if avg > 0 {
+			p.latencies = append(p.latencies, avg)
+		}
+	}
+	p.latencies = append(p.latencies, latency)
+	if len(p.latencies) > window {
+		p.latencies = p.latencies[len(p.latencies)-window:]
+	}
+	var sum int64
+	for _, item := range p.latencies {
+		sum += item
+	}
+	avg := sum / int64(len(p.latencies))
+	atomic.StoreInt64(&p.Latency, latency)
+	atomic.StoreInt64(&p.AvgLatency, avg)
+
+	// 将当前proxy划分对应的分段
+	speed := int32(len(buckets))
+	for index, item := range buckets {
+		if time.Duration(avg)*time.Millisecond < item {
+			speed = int32(index)
+			break
+		}
+	}
+	atomic.StoreInt32(&p.Speed, speed)
+}
+
 func (pl *ProxyList) indexOf(p *Proxy) int {
 	index := -1
 	for i, item := range pl.data {
@@ -51,6 +343,47 @@ func (pl *ProxyList) indexOf(p *Proxy) int {
 	return index
 }
 
+// seen merge the seen time of the same proxy
+func (p *Proxy) seen(other *Proxy) {
+	if p == other {
+		return
+	}
+	firstSeenAt := atomic.LoadInt64(&p.FirstSeenAt)
+	if other.FirstSeenAt != 0 && (firstSeenAt == 0 || other.FirstSeenAt < firstSeenAt) {
+		atomic.StoreInt64(&p.FirstSeenAt, other.FirstSeenAt)
+	}
+	if other.LastSeenAt > atomic.LoadInt64(&p.LastSeenAt) {
+		atomic.StoreInt64(&p.LastSeenAt, other.LastSeenAt)
+	}
+}
+
+// get get the proxy in list which has the same ip, port and category
+func (pl *ProxyList) get(p *Proxy) *Proxy {
+	pl.RLock()
+	defer pl.RUnlock()
+	index := pl.indexOf(p)
+	if index == -1 {
+		return nil
+	}
+	return pl.data[index]
+}
+
+// Touch update the seen time of the proxy in list, return false if the proxy doesn't exist
+func (pl *ProxyList) Touch(p *Proxy) bool {
+	pl.RLock()
+	index := pl.indexOf(p)
+	if index == -1 {
+		pl.RUnlock()
+		return false
+	}
+	found := pl.data[index]
+	found.seen(p)
+	pl.RUnlock()
+	// 更新后的来源与时间也需要保存，避免重启或其它实例加载时丢失
+	pl.SaveLater(found)
+	return true
+}
+
 // Exists test whether or not the proxy exists
 func (pl *ProxyList) Exists(p *Proxy) bool {
 	pl.RLock()
@@ -64,16 +397,23 @@ func (pl *ProxyList) Add(list ...*Proxy) {
 		return
 	}
 	pl.Lock()
-	defer pl.Unlock()
 	if len(pl.data) == 0 {
 		pl.data = make([]*Proxy, 0, 100)
 	}
+	added := make([]*Proxy, 0, len(list))
 	for _, p := range list {
-		if pl.indexOf(p) != -1 {
+		index := pl.indexOf(p)
+		// 已存在的则更新其抓取时间
+		if index != -1 {
+			pl.data[index].seen(p)
 			continue
 		}
 		pl.data = append(pl.data, p)
+		added = append(added, p)
 	}
+	pl.Unlock()
+	// 释放锁后再保存，避免获取代理时等待存储的读写
+	pl.Save(added...)
 }
 
 // Remove remove proxy from list
@@ -82,8 +422,8 @@ func (pl *ProxyList) Remove(list ...*Proxy) {
 		return
 	}
 	pl.Lock()
-	defer pl.Unlock()
 	if len(pl.data) == 0 {
+		pl.Unlock()
 		return
 	}
 	for _, p := range list {
@@ -92,6 +432,156 @@ func (pl *ProxyList) Remove(list ...*Proxy) {
 			pl.data = append(pl.data[:index], pl.data[index+1:]...)
 		}
 	}
+	store := pl.store
+	pl.Unlock()
+	if store == nil {
+		return
+	}
+	pl.storeMutex.Lock()
+	defer pl.storeMutex.Unlock()
+	err := store.Remove(list...)
+	if err != nil {
+		logger.Error("remove proxy from store fail",
+			zap.Error(err),
+		)
+	}
+}
+
+// save save the proxies to store, it should be called without the lock of list
+func save(store ProxyStore, list ...*Proxy) {
+	if store == nil || len(list) == 0 {
+		return
+	}
+	err := store.Save(list...)
+	if err != nil {
+		logger.Error("save proxy to store fail",
+			zap.Error(err),
+		)
+	}
+}
+
+// SetStore set the store of proxy list, and load the proxies from store
+func (pl *ProxyList) SetStore(store ProxyStore) (err error) {
+	list, err := store.Load()
+	if err != nil {
+		return
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	pl.store = store
+	for _, p := range list {
+		if pl.indexOf(p) != -1 {
+			continue
+		}
+		pl.data = append(pl.data, p)
+	}
+	return
+}
+
+// Reload reload the proxies from store, it's used for the store shared by several instances.
+// The proxies waiting to be saved are saved first
+func (pl *ProxyList) Reload() (err error) {
+	if pl.store == nil {
+		return
+	}
+	pl.Flush()
+	list, err := pl.store.Load()
+	if err != nil {
+		return
+	}
+	pl.Lock()
+	defer pl.Unlock()
+	pl.data = list
+	return
+}
+
+// Acquire acquire the lock of name from store, if the store isn't a locker, it always succeeds
+func (pl *ProxyList) Acquire(name string, ttl time.Duration) (bool, error) {
+	locker, ok := pl.store.(ProxyLocker)
+	if !ok {
+		return true, nil
+	}
+	return locker.Lock(name, ttl)
+}
+
+// Shared test whether or not the store is shared by several instances
+func (pl *ProxyList) Shared() bool {
+	pl.RLock()
+	defer pl.RUnlock()
+	_, ok := pl.store.(ProxyLocker)
+	return ok
+}
+
+// Extend extend the ttl of the lock acquired, if the store isn't a locker, it always succeeds
+func (pl *ProxyList) Extend(name string, ttl time.Duration) (bool, error) {
+	locker, ok := pl.store.(ProxyLocker)
+	if !ok {
+		return true, nil
+	}
+	return locker.Extend(name, ttl)
+}
+
+// Save save the proxies to store, it should be called after the proxies are updated.
+// The proxies which are not in the list will be ignored.
+func (pl *ProxyList) Save(list ...*Proxy) {
+	if len(list) == 0 {
+		return
+	}
+	// 在存储的锁中判断是否存在，避免保存已被删除的代理
+	pl.storeMutex.Lock()
+	defer pl.storeMutex.Unlock()
+	pl.RLock()
+	existsList := make([]*Proxy, 0, len(list))
+	for _, p := range list {
+		if pl.indexOf(p) != -1 {
+			existsList = append(existsList, p)
+		}
+	}
+	store := pl.store
+	pl.RUnlock()
+	save(store, existsList...)
+}
+
+// SaveLater mark the proxies to be saved, they are saved in batch
+// by Flush, which is called every flush interval
+func (pl *ProxyList) SaveLater(list ...*Proxy) {
+	pl.RLock()
+	store := pl.store
+	pl.RUnlock()
+	if store == nil || len(list) == 0 {
+		return
+	}
+	pl.dirtyMutex.Lock()
+	if pl.dirty == nil {
+		pl.dirty = make(map[string]*Proxy)
+	}
+	for _, p := range list {
+		pl.dirty[p.Key()] = p
+	}
+	pl.dirtyMutex.Unlock()
+	pl.flushOnce.Do(func() {
+		go func() {
+			for range time.NewTicker(flushInterval).C {
+				pl.Flush()
+			}
+		}()
+	})
+}
+
+// Flush save the proxies marked by SaveLater
+func (pl *ProxyList) Flush() {
+	pl.dirtyMutex.Lock()
+	dirty := pl.dirty
+	pl.dirty = nil
+	pl.dirtyMutex.Unlock()
+	if len(dirty) == 0 {
+		return
+	}
+	list := make([]*Proxy, 0, len(dirty))
+	for _, p := range dirty {
+		list = append(list, p)
+	}
+	pl.Save(list...)
 }
 
 // List get proxy list
@@ -124,13 +614,79 @@ func (pl *ProxyList) Replace(list []*Proxy) {
 	pl.data = list
 }
 
+// match test whether or not the proxy matches all filters
+func match(p *Proxy, filters []ProxyFilter) bool {
+	for _, fn := range filters {
+		if !fn(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter get the proxies which match all filters
+func (pl *ProxyList) Filter(filters ...ProxyFilter) []*Proxy {
+	pl.RLock()
+	defer pl.RUnlock()
+	if len(filters) == 0 {
+		return pl.data[:]
+	}
+	list := make([]*Proxy, 0, len(pl.data))
+	for _, item := range pl.data {
+		if match(item, filters) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 // FindOne find one proxy
-func (pl *ProxyList) FindOne(category string, speed int32) (p *Proxy) {
+func (pl *ProxyList) FindOne(category string, speed int32, filters ...ProxyFilter) (p *Proxy) {
+	return pl.Find(FindOptions{
+		Category: category,
+		Speed:    speed,
+	}, filters...)
+}
+
+// Find find one proxy matches the options and filters, the leased proxies are skipped
+func (pl *ProxyList) Find(opts FindOptions, filters ...ProxyFilter) *Proxy {
 	pl.RLock()
 	defer pl.RUnlock()
+	return pl.find(opts, filters...)
+}
+
+// matchCategory test whether or not the category of proxy matches the options
+func (opts FindOptions) matchCategory(p *Proxy) bool {
+	if opts.Category != "" {
+		return p.Category == opts.Category
+	}
+	if len(opts.Categories) != 0 {
+		return containsString(opts.Categories, p.Category)
+	}
+	return true
+}
+
+// find find one proxy without lock
+func (pl *ProxyList) find(opts FindOptions, filters ...ProxyFilter) (p *Proxy) {
+	// 按优先顺序查找各类型的代理
+	if opts.Category == "" && len(opts.Categories) != 0 {
+		for _, category := range opts.Categories {
+			o := opts
+			o.Category = category
+			o.Categories = nil
+			p = pl.find(o, filters...)
+			if p != nil {
+				return
+			}
+		}
+		return
+	}
+	category := opts.Category
+	speed := opts.Speed
 	list := pl.data
-	// 指定了速度或者代理类型
-	if speed >= 0 || category != "" {
+	now := time.Now().UnixNano()
+	// 指定了速度、代理类型、过滤函数或有被租用的代理
+	if speed >= 0 || category != "" || len(filters) != 0 || len(pl.leasedUntil) != 0 {
 		list = make([]*Proxy, 0, 10)
 		for _, item := range pl.data {
 			if speed >= 0 && item.Speed != speed {
@@ -139,13 +695,106 @@ func (pl *ProxyList) FindOne(category string, speed int32) (p *Proxy) {
 			if category != "" && item.Category != category {
 				continue
 			}
+			if pl.isLeased(item, now) {
+				continue
+			}
+			if !match(item, filters) {
+				continue
+			}
 			list = append(list, item)
 		}
 	}
-	size := len(list)
-	if size == 0 {
+	if opts.DistinctExit {
+		list = pl.leastUsedExit(list)
+	}
+	p = selectProxy(list, opts.Strategy, &pl.cursor)
+	if p == nil {
 		return
 	}
-	rand.Seed(time.Now().UnixNano())
-	return list[rand.Intn(size)]
+	atomic.StoreInt64(&p.SelectedAt, time.Now().UnixNano())
+	if opts.DistinctExit {
+		pl.exitMutex.Lock()
+		pl.exitUsedAt[p.exitKey()] = time.Now().UnixNano()
+		pl.exitMutex.Unlock()
+	}
+	return
+}
+
+// pruneExits remove the selected time of the exits which aren't used by any proxy in list
+func (pl *ProxyList) pruneExits() {
+	pl.RLock()
+	exits := make(map[string]bool, len(pl.data))
+	for _, item := range pl.data {
+		exits[item.exitKey()] = true
+	}
+	pl.RUnlock()
+	pl.exitMutex.Lock()
+	defer pl.exitMutex.Unlock()
+	for key := range pl.exitUsedAt {
+		if !exits[key] {
+			delete(pl.exitUsedAt, key)
+		}
+	}
+}
+
+// leastUsedExit get the proxies whose exit ip is least recently used
+func (pl *ProxyList) leastUsedExit(list []*Proxy) []*Proxy {
+	pl.exitMutex.Lock()
+	defer pl.exitMutex.Unlock()
+	if pl.exitUsedAt == nil {
+		pl.exitUsedAt = make(map[string]int64)
+	}
+	result := make([]*Proxy, 0)
+	var min int64 = -1
+	for _, item := range list {
+		usedAt := pl.exitUsedAt[item.exitKey()]
+		if min == -1 || usedAt < min {
+			min = usedAt
+			result = result[:0]
+		}
+		if usedAt == min {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// isLeased test whether or not the proxy is leased, it should be called with lock
+func (pl *ProxyList) isLeased(p *Proxy, now int64) bool {
+	return pl.leasedUntil[p.Key()] > now
+}
+
+// lease find one proxy and mark it as leased until the expired time
+func (pl *ProxyList) lease(expiredAt time.Time, opts FindOptions, filters ...ProxyFilter) *Proxy {
+	pl.Lock()
+	defer pl.Unlock()
+	p := pl.find(opts, filters...)
+	if p == nil {
+		return nil
+	}
+	if pl.leasedUntil == nil {
+		pl.leasedUntil = make(map[string]int64)
+	}
+	pl.leasedUntil[p.Key()] = expiredAt.UnixNano()
+	return p
+}
+
+// unlease remove the leased mark of proxy, the mark of the proxy
+// leased again after the lease expires is kept
+func (pl *ProxyList) unlease(list ...*Lease) {
+	pl.Lock()
+	defer pl.Unlock()
+	for _, l := range list {
+		key := l.Proxy.Key()
+		if pl.leasedUntil[key] == l.ExpiredAt.UnixNano() {
+			delete(pl.leasedUntil, key)
+		}
+	}
+}
+
+// leased test whether or not the proxy is leased now
+func (pl *ProxyList) leased(p *Proxy) bool {
+	pl.RLock()
+	defer pl.RUnlock()
+	return pl.isLeased(p, time.Now().UnixNano())
 }
@@ -0,0 +1,132 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/vicanso/proxy-pool/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// OutcomeSuccess the request through proxy is successful
+	OutcomeSuccess = "success"
+	// OutcomeFailure the request through proxy is fail
+	OutcomeFailure = "failure"
+	// OutcomeBlocked the proxy is blocked by the target
+	OutcomeBlocked = "blocked"
+	// OutcomeTimeout the request through proxy is timeout
+	OutcomeTimeout = "timeout"
+)
+
+var (
+	// ErrProxyNotFound the proxy isn't in the available list
+	ErrProxyNotFound = errors.New("proxy not found")
+	// ErrInvalidOutcome the outcome isn't supported
+	ErrInvalidOutcome = errors.New("outcome is invalid")
+)
+
+// IsValidOutcome test whether or not the outcome is supported
+func IsValidOutcome(outcome string) bool {
+	switch outcome {
+	case OutcomeSuccess,
+		OutcomeFailure,
+		OutcomeBlocked,
+		OutcomeTimeout:
+		return true
+	}
+	return false
+}
+
+// Report report the outcome of the request through proxy from client or gateway,
+// the proxy is evicted if it fails too many times, otherwise it's redetected.
+// If the host is set, the outcome is recorded for the host, the blocked outcome
+// only affects the host, and the other fail outcomes only trigger redetection
+func (c *Crawler) Report(p *Proxy, outcome, host string) (err error) {
+	if !IsValidOutcome(outcome) {
+		return ErrInvalidOutcome
+	}
+	found := c.avaliableProxyList.get(p)
+	if found == nil {
+		return ErrProxyNotFound
+	}
+	metrics.IncReport(outcome)
+	logger.Debug("proxy is reported",
+		zap.String("proxy", found.Key()),
+		zap.String("outcome", outcome),
+		zap.String("host", host),
+	)
+	if host != "" {
+		c.domainHealth.record(found, host, outcome)
+	}
+	if outcome == OutcomeSuccess {
+		atomic.AddInt64(&found.Successes, 1)
+		atomic.StoreInt32(&found.Fails, 0)
+		// 网关的每个请求均会反馈，批量保存避免频繁写存储
+		c.avaliableProxyList.SaveLater(found)
+		return
+	}
+	if host != "" {
+		// 被目标网站禁止不影响代理在其它网站的使用
+		if outcome == OutcomeBlocked {
+			return
+		}
+		// 可能只是该网站无法访问，不增加失败次数，重新检测（检测目标）确认代理是否可用
+		go c.redetectProxy(found)
+		return
+	}
+	atomic.AddInt64(&found.Failures, 1)
+	if c.fail(found) {
+		return
+	}
+	// 失败但未达到删除的次数，重新检测
+	go c.redetectProxy(found)
+	return
+}
+
+// fail increase the fails of proxy, it will be evicted
+// if the fails reach the limit, return true if it's evicted
+func (c *Crawler) fail(p *Proxy) bool {
+	count := atomic.AddInt32(&p.Fails, 1)
+	if count < maxProxyFails {
+		return false
+	}
+	atomic.AddInt64(&getSourceStats(p.Source).Evicted, 1)
+	c.avaliableProxyList.Remove(p)
+	return true
+}
+
+// redetectProxy redetect the proxy, it's skipped if the proxy is detecting
+func (c *Crawler) redetectProxy(p *Proxy) {
+	if !atomic.CompareAndSwapInt32(&p.detecting, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&p.detecting, 0)
+	available := c.analyze(p)
+	atomic.StoreInt64(&p.DetectedAt, time.Now().Unix())
+	if available {
+		atomic.AddInt64(&p.Successes, 1)
+		atomic.StoreInt32(&p.Fails, 0)
+	} else {
+		atomic.AddInt64(&p.Failures, 1)
+		if c.fail(p) {
+			return
+		}
+	}
+	c.avaliableProxyList.Save(p)
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	p := &Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+		Fails:    1,
+	}
+	c.avaliableProxyList.Add(p)
+	key := &Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+	}
+
+	assert.Equal(ErrInvalidOutcome, c.Report(key, "abc", ""))
+	assert.Equal(ErrProxyNotFound, c.Report(&Proxy{
+		IP:       "127.0.0.2",
+		Port:     "80",
+		Category: "http",
+	}, OutcomeSuccess, ""))
+
+	assert.Nil(c.Report(key, OutcomeSuccess, "example.com"))
+	assert.Equal(int64(1), p.Successes)
+	assert.Equal(int32(0), p.Fails)
+
+	// 指定目标网站的失败只影响该网站（正在检测中，不会再触发重新检测）
+	p.detecting = 1
+	for i := 0; i < maxProxyFails; i++ {
+		assert.Nil(c.Report(key, OutcomeTimeout, "example.com"))
+		assert.Nil(c.Report(key, OutcomeFailure, "example.com"))
+		assert.Nil(c.Report(key, OutcomeBlocked, "example.com"))
+	}
+	assert.Equal(int64(0), p.Failures)
+	assert.Equal(int32(0), p.Fails)
+	assert.Equal(1, c.avaliableProxyList.Size())
+	assert.False(c.NewDomainFilter("example.com")(p))
+
+	// 未指定目标网站的禁止等同于失败
+	assert.Nil(c.Report(key, OutcomeBlocked, ""))
+	assert.Nil(c.Report(key, OutcomeTimeout, ""))
+	assert.Equal(int64(2), p.Failures)
+	assert.Equal(1, c.avaliableProxyList.Size())
+	// 失败次数达到上限则删除
+	assert.Nil(c.Report(key, OutcomeFailure, ""))
+	assert.Equal(0, c.avaliableProxyList.Size())
+	assert.Equal(ErrProxyNotFound, c.Report(key, OutcomeFailure, ""))
+}
+
+// testStore store for test, count the saved proxies
+type testStore struct {
+	sync.Mutex
+	saved int
+}
+
+func (s *testStore) Load() ([]*Proxy, error) {
+	return nil, nil
+}
+
+func (s *testStore) Save(list ...*Proxy) error {
+	s.Lock()
+	defer s.Unlock()
+	s.saved += len(list)
+	return nil
+}
+
+func (s *testStore) Remove(list ...*Proxy) error {
+	return nil
+}
+
+func TestReportSaveLater(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	store := new(testStore)
+	assert.Nil(c.SetStore(store))
+	p := &Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+	}
+	c.avaliableProxyList.Add(p)
+	store.saved = 0
+
+	// 成功的反馈批量保存
+	for i := 0; i < 10; i++ {
+		assert.Nil(c.Report(p, OutcomeSuccess, "example.com"))
+	}
+	assert.Equal(0, store.saved)
+	c.avaliableProxyList.Flush()
+	assert.Equal(1, store.saved)
+	c.avaliableProxyList.Flush()
+	assert.Equal(1, store.saved)
+
+	// 重新加载前先保存
+	assert.Nil(c.Report(p, OutcomeSuccess, ""))
+	assert.Nil(c.SyncAvailableProxy())
+	assert.Equal(2, store.saved)
+}
+
+func TestReportRedetect(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	// 不可连接的代理
+	p := &Proxy{
+		IP:       "127.0.0.1",
+		Port:     "1",
+		Category: "http",
+	}
+	c.avaliableProxyList.Add(p)
+	// 指定目标网站的失败重新检测，检测失败则增加失败次数
+	assert.Nil(c.Report(p, OutcomeFailure, "example.com"))
+	for i := 0; i < 100 && atomic.LoadInt64(&p.DetectedAt) == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&p.detecting) != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&p.Fails))
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// SourceSeed source of the seed proxy
+	SourceSeed = "seed"
+)
+
+type (
+	// seedProxy seed proxy, load the proxies from config and file
+	seedProxy struct {
+		baseProxyCrawler
+		list []string
+		file string
+	}
+)
+
+// NewSeedProxy create a new seed proxy crawler, the seeds are reloaded every interval
+func NewSeedProxy(interval time.Duration, list []string, file string) *seedProxy {
+	sp := new(seedProxy)
+	sp.name = SourceSeed
+	sp.interval = interval
+	sp.list = list
+	sp.file = file
+	return sp
+}
+
+// Start start the crawler
+func (sp *seedProxy) Start() {
+	sp.status = StatusRunning
+	for {
+		if sp.status != StatusRunning {
+			return
+		}
+		sp.fetch()
+		time.Sleep(sp.interval)
+	}
+}
+
+// load load the seed proxies from config and file
+func (sp *seedProxy) load() []*Proxy {
+	list := ParseProxyList([]byte(strings.Join(sp.list, "\n")), CategoryHTTP)
+	if sp.file != "" {
+		// 每次均重新读取文件，修改文件后无需重启
+		data, err := ioutil.ReadFile(sp.file)
+		if err != nil {
+			logger.Error("read seed file fail",
+				zap.String("file", sp.file),
+				zap.Error(err),
+			)
+		} else {
+			list = append(list, ParseProxyList(data, CategoryHTTP)...)
+		}
+	}
+	return list
+}
+
+// fetch fetch the seed proxies
+func (sp *seedProxy) fetch() {
+	if sp.fetchListener == nil {
+		return
+	}
+	for _, p := range sp.load() {
+		sp.emit(p)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// StrategyRandom select the proxy randomly
+	StrategyRandom = "random"
+	// StrategyRoundRobin select the proxy in turn
+	StrategyRoundRobin = "round-robin"
+	// StrategyLRU select the least recently selected proxy
+	StrategyLRU = "lru"
+	// StrategyLowestLatency select the proxy with the lowest average latency
+	StrategyLowestLatency = "lowest-latency"
+	// StrategySuccessRate select the proxy randomly, weighted by success rate
+	StrategySuccessRate = "success-rate"
+)
+
+var (
+	// 只初始化一次随机数，rand.Rand非并发安全，需要加锁
+	selectRandMutex = sync.Mutex{}
+	selectRand      = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// IsValidStrategy test whether or not the strategy is supported
+func IsValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyRandom,
+		StrategyRoundRobin,
+		StrategyLRU,
+		StrategyLowestLatency,
+		StrategySuccessRate:
+		return true
+	}
+	return false
+}
+
+// randomInt get a random int in [0, n)
+func randomInt(n int) int {
+	selectRandMutex.Lock()
+	defer selectRandMutex.Unlock()
+	return selectRand.Intn(n)
+}
+
+// randomFloat get a random float in [0, max)
+func randomFloat(max float64) float64 {
+	selectRandMutex.Lock()
+	defer selectRandMutex.Unlock()
+	return selectRand.Float64() * max
+}
+
+// successRate get the success rate of proxy,
+// laplace smoothing is used so the new proxy isn't zero
+func (p *Proxy) successRate() float64 {
+	successes := atomic.LoadInt64(&p.Successes)
+	failures := atomic.LoadInt64(&p.Failures)
+	return float64(successes+1) / float64(successes+failures+2)
+}
+
+// selectProxy select one proxy from the list by strategy,
+// the cursor is used for round robin
+func selectProxy(list []*Proxy, strategy string, cursor *uint64) *Proxy {
+	size := len(list)
+	if size == 0 {
+		return nil
+	}
+	switch strategy {
+	case StrategyRoundRobin:
+		index := atomic.AddUint64(cursor, 1) - 1
+		return list[index%uint64(size)]
+	case StrategyLRU:
+		var result *Proxy
+		var min int64
+		for _, item := range list {
+			selectedAt := atomic.LoadInt64(&item.SelectedAt)
+			if result == nil || selectedAt < min {
+				result = item
+				min = selectedAt
+			}
+		}
+		return result
+	case StrategyLowestLatency:
+		var result *Proxy
+		var min int64
+		for _, item := range list {
+			latency := atomic.LoadInt64(&item.AvgLatency)
+			// 未检测延时的排在最后
+			if latency <= 0 {
+				continue
+			}
+			if result == nil || latency < min {
+				result = item
+				min = latency
+			}
+		}
+		if result != nil {
+			return result
+		}
+	case StrategySuccessRate:
+		rates := make([]float64, size)
+		var total float64
+		for i, item := range list {
+			rates[i] = item.successRate()
+			total += rates[i]
+		}
+		value := randomFloat(total)
+		for i, rate := range rates {
+			value -= rate
+			if value < 0 {
+				return list[i]
+			}
+		}
+		return list[size-1]
+	}
+	return list[randomInt(size)]
+}
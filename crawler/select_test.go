@@ -0,0 +1,92 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSelectTestList() *ProxyList {
+	pl := &ProxyList{}
+	pl.Add(&Proxy{
+		IP:         "127.0.0.1",
+		Port:       "80",
+		Category:   "http",
+		AvgLatency: 300,
+		Successes:  1,
+		Failures:   100,
+	}, &Proxy{
+		IP:         "127.0.0.2",
+		Port:       "80",
+		Category:   "http",
+		AvgLatency: 100,
+		Successes:  1,
+		Failures:   100,
+	}, &Proxy{
+		IP:        "127.0.0.3",
+		Port:      "80",
+		Category:  "http",
+		Successes: 10000,
+	})
+	return pl
+}
+
+func TestSelectStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsValidStrategy(StrategyRoundRobin))
+	assert.False(IsValidStrategy("abc"))
+
+	pl := newSelectTestList()
+	list := pl.List()
+	opts := FindOptions{
+		Speed:    -1,
+		Strategy: StrategyRoundRobin,
+	}
+	for i := 0; i < 6; i++ {
+		assert.Equal(list[i%3], pl.Find(opts))
+	}
+
+	// lru选择最久未被选择的代理
+	pl = newSelectTestList()
+	opts.Strategy = StrategyLRU
+	selected := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		selected[pl.Find(opts).IP] = true
+	}
+	assert.Equal(3, len(selected))
+
+	pl = newSelectTestList()
+	opts.Strategy = StrategyLowestLatency
+	assert.Equal("127.0.0.2", pl.Find(opts).IP)
+
+	// 按成功率加权，成功率高的代理被选中的概率大
+	pl = newSelectTestList()
+	opts.Strategy = StrategySuccessRate
+	count := 0
+	for i := 0; i < 100; i++ {
+		if pl.Find(opts).IP == "127.0.0.3" {
+			count++
+		}
+	}
+	assert.True(count > 80)
+
+	opts.Strategy = StrategyRandom
+	p := pl.Find(opts)
+	assert.NotNil(p)
+	assert.NotEqual(int64(0), p.SelectedAt)
+}
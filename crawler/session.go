@@ -0,0 +1,97 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// 清除过期会话的间隔
+	sessionCleanInterval = time.Minute
+)
+
+type (
+	// session the proxy pinned by session key
+	session struct {
+		proxy     *Proxy
+		expiredAt time.Time
+	}
+	// sessionTable session table, the expired sessions are removed automatically
+	sessionTable struct {
+		sync.Mutex
+		once sync.Once
+		data map[string]*session
+	}
+)
+
+// clean remove the expired sessions
+func (t *sessionTable) clean() {
+	now := time.Now()
+	t.Lock()
+	defer t.Unlock()
+	for key, s := range t.data {
+		if now.After(s.expiredAt) {
+			delete(t.data, key)
+		}
+	}
+}
+
+// init init the data and start the cleaner
+func (t *sessionTable) init() {
+	t.once.Do(func() {
+		go func() {
+			for range time.NewTicker(sessionCleanInterval).C {
+				t.clean()
+			}
+		}()
+	})
+	if t.data == nil {
+		t.data = make(map[string]*session)
+	}
+}
+
+// FindSessionProxy find the proxy pinned by the session key, the same proxy is returned
+// until the ttl expires or the proxy is evicted. A new proxy is pinned if the pinned one
+// doesn't match the options and filters, and the pinned one is kept if no proxy matches
+func (c *Crawler) FindSessionProxy(key string, ttl time.Duration, opts FindOptions, filters ...ProxyFilter) *Proxy {
+	t := &c.sessions
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	if s := t.data[key]; s != nil && time.Now().Before(s.expiredAt) {
+		// 代理仍在可用列表中且满足条件则继续使用
+		p := c.avaliableProxyList.get(s.proxy)
+		if p != nil &&
+			!c.avaliableProxyList.leased(p) &&
+			opts.matchCategory(p) &&
+			(opts.Speed < 0 || p.Speed == opts.Speed) &&
+			match(p, filters) {
+			return p
+		}
+	}
+	p := c.avaliableProxyList.Find(opts, filters...)
+	// 无满足条件的代理时保留原有的绑定（如https的请求而会话绑定的是http代理），
+	// 过期后自动清除
+	if p == nil {
+		return nil
+	}
+	t.data[key] = &session{
+		proxy:     p,
+		expiredAt: time.Now().Add(ttl),
+	}
+	return p
+}
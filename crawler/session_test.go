@@ -0,0 +1,114 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionProxy(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	for i := 1; i <= 10; i++ {
+		c.avaliableProxyList.Add(&Proxy{
+			IP:       "127.0.0." + strconv.Itoa(i),
+			Port:     "80",
+			Category: "http",
+		})
+	}
+	opts := FindOptions{
+		Speed: -1,
+	}
+	p := c.FindSessionProxy("a", time.Minute, opts)
+	assert.NotNil(p)
+
+	// 并发获取同一会话返回同一代理
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(p, c.FindSessionProxy("a", time.Minute, opts))
+		}()
+	}
+	wg.Wait()
+
+	// 不满足条件则重新绑定
+	other := c.FindSessionProxy("a", time.Minute, opts, func(item *Proxy) bool {
+		return item != p
+	})
+	assert.NotEqual(p, other)
+	assert.Equal(other, c.FindSessionProxy("a", time.Minute, opts))
+
+	// 代理被删除后重新绑定
+	c.avaliableProxyList.Remove(other)
+	p = c.FindSessionProxy("a", time.Minute, opts)
+	assert.NotEqual(other, p)
+	assert.NotNil(p)
+
+	// 过期后清除
+	c.FindSessionProxy("b", time.Millisecond, opts)
+	time.Sleep(2 * time.Millisecond)
+	c.sessions.clean()
+	assert.Nil(c.sessions.data["b"])
+	assert.NotNil(c.sessions.data["a"])
+}
+
+func TestSessionProxyCategories(t *testing.T) {
+	assert := assert.New(t)
+	c := new(Crawler)
+	for i := 1; i <= 20; i++ {
+		c.avaliableProxyList.Add(&Proxy{
+			IP:       "127.0.0." + strconv.Itoa(i),
+			Port:     "80",
+			Category: CategoryHTTP,
+		})
+	}
+	// 网关的http请求优先https代理，无https代理时使用http代理
+	httpOpts := FindOptions{
+		Speed: -1,
+		Categories: []string{
+			CategoryHTTPS,
+			CategoryHTTP,
+		},
+	}
+	httpsOpts := FindOptions{
+		Speed:    -1,
+		Category: CategoryHTTPS,
+	}
+	p := c.FindSessionProxy("a", time.Minute, httpOpts)
+	assert.NotNil(p)
+	for i := 0; i < 10; i++ {
+		// 无https代理，不影响会话已绑定的http代理
+		assert.Nil(c.FindSessionProxy("a", time.Minute, httpsOpts))
+		assert.Equal(p, c.FindSessionProxy("a", time.Minute, httpOpts))
+	}
+
+	// 有https代理后，https的请求绑定https代理，http的请求继续使用该代理
+	httpsProxy := &Proxy{
+		IP:       "127.0.1.1",
+		Port:     "443",
+		Category: CategoryHTTPS,
+	}
+	c.avaliableProxyList.Add(httpsProxy)
+	assert.Equal(httpsProxy, c.FindSessionProxy("a", time.Minute, httpsOpts))
+	assert.Equal(httpsProxy, c.FindSessionProxy("a", time.Minute, httpOpts))
+	assert.Equal(httpsProxy, c.FindSessionProxy("b", time.Minute, httpOpts))
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	socks4Version        = 0x04
+	socks4CommandConnect = 0x01
+	socks4Granted        = 0x5a
+)
+
+type (
+	// socks4Dialer dial the address through socks4 proxy
+	socks4Dialer struct {
+		// proxy address
+		addr string
+		// user id of socks4
+		userID string
+		dialer *net.Dialer
+	}
+)
+
+var (
+	errSocks4IPv4Only = errors.New("socks4 only supports ipv4 address")
+)
+
+// newSocks4Dialer create a new socks4 dialer
+func newSocks4Dialer(addr, userID string, dialer *net.Dialer) *socks4Dialer {
+	return &socks4Dialer{
+		addr:   addr,
+		userID: userID,
+		dialer: dialer,
+	}
+}
+
+// lookupIPv4 get the ipv4 address of host
+func lookupIPv4(ctx context.Context, host string) (net.IP, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range addrs {
+			if item.IP.To4() != nil {
+				ip = item.IP
+				break
+			}
+		}
+	}
+	if ip == nil || ip.To4() == nil {
+		return nil, errSocks4IPv4Only
+	}
+	return ip.To4(), nil
+}
+
+// DialContext connect to the address through socks4 proxy
+func (d *socks4Dialer) DialContext(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	host, portValue, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil {
+		return
+	}
+	// socks4不支持域名，由本地解析
+	ip, err := lookupIPv4(ctx, host)
+	if err != nil {
+		return
+	}
+	conn, err = d.dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer func() {
+			_ = conn.SetDeadline(time.Time{})
+		}()
+	}
+	// VN CD DSTPORT DSTIP USERID NULL
+	req := make([]byte, 0, 9+len(d.userID))
+	req = append(req, socks4Version, socks4CommandConnect)
+	req = append(req, byte(port>>8), byte(port))
+	req = append(req, ip...)
+	req = append(req, d.userID...)
+	req = append(req, 0)
+	_, err = conn.Write(req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// VN CD DSTPORT DSTIP
+	resp := make([]byte, 8)
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp[1] != socks4Granted {
+		conn.Close()
+		return nil, fmt.Errorf("socks4 request is rejected, code:%d", resp[1])
+	}
+	return conn, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/proxy-pool/config"
+)
+
+// pipeConn forward data between two connections
+func pipeConn(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		a.Close()
+	}()
+	_, _ = io.Copy(b, a)
+	b.Close()
+}
+
+// newSocksServer create a simple socks server for test
+func newSocksServer(t *testing.T, handshake func(conn net.Conn) (string, error)) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				addr, err := handshake(conn)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				pipeConn(conn, target)
+			}()
+		}
+	}()
+	return ln
+}
+
+func socks4Handshake(conn net.Conn) (addr string, err error) {
+	buf := make([]byte, 8)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return
+	}
+	// user id
+	b := make([]byte, 1)
+	for {
+		_, err = conn.Read(b)
+		if err != nil || b[0] == 0 {
+			break
+		}
+	}
+	port := binary.BigEndian.Uint16(buf[2:4])
+	addr = net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(port)))
+	_, err = conn.Write([]byte{0, socks4Granted, 0, 0, 0, 0, 0, 0})
+	return
+}
+
+func socks5Handshake(conn net.Conn) (addr string, err error) {
+	// VER NMETHODS METHODS
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return
+	}
+	_, err = io.ReadFull(conn, make([]byte, buf[1]))
+	if err != nil {
+		return
+	}
+	_, err = conn.Write([]byte{5, 0})
+	if err != nil {
+		return
+	}
+	// VER CMD RSV ATYP
+	buf = make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, err = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	default:
+		size := make([]byte, 1)
+		_, err = io.ReadFull(conn, size)
+		if err != nil {
+			return
+		}
+		name := make([]byte, size[0])
+		_, err = io.ReadFull(conn, name)
+		host = string(name)
+	}
+	if err != nil {
+		return
+	}
+	port := make([]byte, 2)
+	_, err = io.ReadFull(conn, port)
+	if err != nil {
+		return
+	}
+	addr = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	_, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return
+}
+
+func TestSocksProxyClient(t *testing.T) {
+	assert := assert.New(t)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer target.Close()
+
+	socks4 := newSocksServer(t, socks4Handshake)
+	defer socks4.Close()
+	socks5 := newSocksServer(t, socks5Handshake)
+	defer socks5.Close()
+
+	for category, ln := range map[string]net.Listener{
+		CategorySOCKS4: socks4,
+		CategorySOCKS5: socks5,
+	} {
+		host, port, _ := net.SplitHostPort(ln.Addr().String())
+		p := &Proxy{
+			IP:       host,
+			Port:     port,
+			Category: category,
+		}
+		client := NewProxyClient(p)
+		resp, err := client.Get(target.URL)
+		assert.Nil(err, category)
+		buf, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal("hello", string(buf), category)
+
+		// 检测socks代理
+		originalTargets := detectConfig.Targets
+		detectConfig.Targets = []config.DetectTarget{
+			{
+				Name: "test",
+				URL:  target.URL,
+			},
+		}
+		c := new(Crawler)
+		assert.True(c.analyze(p), category)
+		assert.Equal([]string{"test"}, p.Targets)
+		detectConfig.Targets = originalTargets
+	}
+}
+
+func TestSocks4Rejected(t *testing.T) {
+	assert := assert.New(t)
+	ln := newSocksServer(t, func(conn net.Conn) (string, error) {
+		_, _ = conn.Write([]byte{0, 0x5b, 0, 0, 0, 0, 0, 0})
+		return "", io.EOF
+	})
+	defer ln.Close()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	client := NewProxyClient(&Proxy{
+		IP:       host,
+		Port:     port,
+		Category: CategorySOCKS4,
+	})
+	_, err := client.Get("http://127.0.0.1:80/")
+	assert.NotNil(err)
+}
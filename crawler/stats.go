@@ -0,0 +1,74 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// SourceStats yield stats of the proxy source
+	SourceStats struct {
+		Name string `json:"name"`
+		// 抓取成功的页数
+		Pages int64 `json:"pages"`
+		// 抓取失败的次数
+		FetchFails int64 `json:"fetchFails"`
+		// 解析得到的代理数
+		Parsed int64 `json:"parsed"`
+		// 检测可用的代理数
+		Available int64 `json:"available"`
+		// 重新检测失败被删除的代理数
+		Evicted int64 `json:"evicted"`
+	}
+)
+
+var (
+	sourceStatsMap = sync.Map{}
+)
+
+// getSourceStats get the stats of source, it will be created if not exists
+func getSourceStats(name string) *SourceStats {
+	value, ok := sourceStatsMap.Load(name)
+	if !ok {
+		value, _ = sourceStatsMap.LoadOrStore(name, &SourceStats{
+			Name: name,
+		})
+	}
+	return value.(*SourceStats)
+}
+
+// GetSourceStats get the stats of all sources
+func GetSourceStats() []*SourceStats {
+	result := make([]*SourceStats, 0)
+	sourceStatsMap.Range(func(_, value interface{}) bool {
+		stats := value.(*SourceStats)
+		result = append(result, &SourceStats{
+			Name:       stats.Name,
+			Pages:      atomic.LoadInt64(&stats.Pages),
+			FetchFails: atomic.LoadInt64(&stats.FetchFails),
+			Parsed:     atomic.LoadInt64(&stats.Parsed),
+			Available:  atomic.LoadInt64(&stats.Available),
+			Evicted:    atomic.LoadInt64(&stats.Evicted),
+		})
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
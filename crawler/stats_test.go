@@ -0,0 +1,52 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceStats(t *testing.T) {
+	assert := assert.New(t)
+	name := "stats-test"
+	sp := NewSeedProxy(time.Minute, []string{
+		"1.1.1.1:8080",
+		"2.2.2.2:8080",
+	}, "")
+	sp.name = name
+	sp.OnFetch(func(_ *Proxy) {})
+	sp.fetch()
+
+	stats := getSourceStats(name)
+	atomic.AddInt64(&stats.Pages, 1)
+	atomic.AddInt64(&stats.Evicted, 1)
+
+	var found *SourceStats
+	for _, item := range GetSourceStats() {
+		if item.Name == name {
+			found = item
+		}
+	}
+	assert.NotNil(found)
+	assert.Equal(int64(1), found.Pages)
+	assert.Equal(int64(2), found.Parsed)
+	assert.Equal(int64(1), found.Evicted)
+	// 返回的为复制的数据
+	assert.False(stats == found)
+}
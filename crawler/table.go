@@ -0,0 +1,159 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/vicanso/go-axios"
+)
+
+const (
+	defaultAnonymityKeyword = "高匿"
+	defaultPageURL          = "/%d"
+)
+
+type (
+	// TableProxyConfig config of table proxy crawler
+	TableProxyConfig struct {
+		// Name name of the crawler
+		Name string
+		// BaseURL base url of the proxy website
+		BaseURL string
+		// PageURL url template of page, e.g.: /%d
+		PageURL string
+		// PageSelector selector of the pagination links, the max number is used as max page
+		PageSelector string
+		// RowSelector selector of the table rows
+		RowSelector string
+		// IPIndex column index of ip
+		IPIndex int
+		// PortIndex column index of port
+		PortIndex int
+		// CategoryIndex column index of category, -1 means the column doesn't exist
+		CategoryIndex int
+		// AnonymityIndex column index of anonymity, -1 means the column doesn't exist
+		AnonymityIndex int
+		// AnonymityKeyword the proxy is anonymous if the anonymity column contains the keyword
+		AnonymityKeyword string
+		// Category default category of the proxy
+		Category string
+	}
+	// tableProxy table proxy, crawl the proxy from html table
+	tableProxy struct {
+		baseProxyCrawler
+		conf TableProxyConfig
+	}
+)
+
+// NewTableProxy create a new table proxy crawler
+func NewTableProxy(interval time.Duration, conf TableProxyConfig) *tableProxy {
+	header := make(http.Header)
+	header.Set("User-Agent", defaultUserAgent)
+	ins := axios.NewInstance(&axios.InstanceConfig{
+		BaseURL: conf.BaseURL,
+		Headers: header,
+		Timeout: defaulttProxyTimeout,
+	})
+	if conf.PageURL == "" {
+		conf.PageURL = defaultPageURL
+	}
+	if conf.AnonymityKeyword == "" {
+		conf.AnonymityKeyword = defaultAnonymityKeyword
+	}
+	if conf.Category == "" {
+		conf.Category = CategoryHTTP
+	}
+	tp := new(tableProxy)
+	tp.name = conf.Name
+	tp.interval = interval
+	tp.ins = ins
+	tp.conf = conf
+	return tp
+}
+
+// Start start the crawler
+func (tp *tableProxy) Start() {
+	tp.status = StatusRunning
+	for {
+		if tp.status != StatusRunning {
+			return
+		}
+		_ = tp.fetch()
+		time.Sleep(tp.interval)
+	}
+}
+
+// fetch fetch proxy list from the table of html
+func (tp *tableProxy) fetch() (err error) {
+	conf := tp.conf
+	doc, err := tp.fetchPage(conf.PageURL)
+	if err != nil || doc == nil {
+		return
+	}
+	// 仅在首次获取，取分页链接中最大的数字
+	if tp.maxPage == 0 {
+		max := 0
+		if conf.PageSelector != "" {
+			doc.Find(conf.PageSelector).Each(func(_ int, s *goquery.Selection) {
+				value, _ := strconv.Atoi(strings.TrimSpace(s.Text()))
+				if value > max {
+					max = value
+				}
+			})
+		}
+		if max == 0 {
+			max = 1
+		}
+		if tp.limitMaxPage != 0 && max > tp.limitMaxPage {
+			max = tp.limitMaxPage
+		}
+		tp.maxPage = max
+	}
+	doc.Find(conf.RowSelector).Each(func(_ int, s *goquery.Selection) {
+		tdList := s.Find("td")
+		getText := func(index int) string {
+			return strings.TrimSpace(tdList.Eq(index).Text())
+		}
+		ip := getText(conf.IPIndex)
+		port := getText(conf.PortIndex)
+		category := conf.Category
+		if conf.CategoryIndex >= 0 {
+			category = strings.ToLower(getText(conf.CategoryIndex))
+		}
+		anonymous := false
+		if conf.AnonymityIndex >= 0 {
+			anonymous = strings.Contains(getText(conf.AnonymityIndex), conf.AnonymityKeyword)
+		}
+		// 表头或数据不完整的忽略
+		if ip == "" ||
+			port == "" ||
+			category == "" ||
+			tp.fetchListener == nil {
+			return
+		}
+		tp.emit(&Proxy{
+			IP:        ip,
+			Port:      port,
+			Anonymous: anonymous,
+			Category:  category,
+		})
+	})
+	return
+}
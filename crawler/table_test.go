@@ -0,0 +1,93 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/go-axios"
+)
+
+func TestTableProxy(t *testing.T) {
+	assert := assert.New(t)
+	tp := NewTableProxy(time.Minute, TableProxyConfig{
+		Name:             "test",
+		BaseURL:          "http://127.0.0.1",
+		PageSelector:     ".pages a",
+		RowSelector:      "#list tr",
+		IPIndex:          0,
+		PortIndex:        1,
+		CategoryIndex:    3,
+		AnonymityIndex:   2,
+		AnonymityKeyword: "elite",
+	})
+	html := `<html>
+		<body>
+			<div class="pages">
+				<a>1</a>
+				<a> 12 </a>
+				<a>next</a>
+			</div>
+			<table id="list">
+				<tr><th>IP</th><th>PORT</th><th>Anonymity</th><th>Type</th></tr>
+				<tr><td> 183.166.71.93 </td><td>9999</td><td>elite proxy</td><td>HTTPS</td></tr>
+			</table>
+		</body>
+	</html>`
+	tp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(html),
+	})
+	done := make(chan bool)
+	tp.OnFetch(func(p *Proxy) {
+		assert.Equal("183.166.71.93", p.IP)
+		assert.Equal("9999", p.Port)
+		assert.Equal("https", p.Category)
+		assert.True(p.Anonymous)
+		done <- true
+	})
+	go tp.Start()
+	<-done
+	tp.Stop()
+	assert.Equal(12, tp.maxPage)
+}
+
+func TestTableProxyDefaultCategory(t *testing.T) {
+	assert := assert.New(t)
+	tp := NewTableProxy(time.Minute, TableProxyConfig{
+		Name:           "test",
+		BaseURL:        "http://127.0.0.1",
+		PageURL:        "/free.html",
+		RowSelector:    "tr",
+		PortIndex:      1,
+		CategoryIndex:  -1,
+		AnonymityIndex: -1,
+	})
+	tp.ins.Mock(&axios.Response{
+		Status: 200,
+		Data:   []byte(`<table><tr><td>171.13.103.213</td><td>8080</td></tr></table>`),
+	})
+	list := make([]*Proxy, 0)
+	tp.OnFetch(func(p *Proxy) {
+		list = append(list, p)
+	})
+	assert.Nil(tp.fetch())
+	assert.Equal(1, tp.maxPage)
+	assert.Equal(1, len(list))
+	assert.Equal("http", list[0].Category)
+	assert.False(list[0].Anonymous)
+}
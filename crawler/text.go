@@ -0,0 +1,159 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vicanso/go-axios"
+	"go.uber.org/zap"
+)
+
+type (
+	// textProxy text proxy, crawl the proxy from plain text list
+	textProxy struct {
+		baseProxyCrawler
+		urls     []string
+		category string
+	}
+)
+
+var (
+	errInvalidProxy = errors.New("invalid proxy")
+)
+
+// NewTextProxy create a new text proxy crawler,
+// each line of the text is ip:port or scheme://user:pass@ip:port
+func NewTextProxy(name string, interval time.Duration, urls []string, category string) *textProxy {
+	header := make(http.Header)
+	header.Set("User-Agent", defaultUserAgent)
+	ins := axios.NewInstance(&axios.InstanceConfig{
+		Headers: header,
+		Timeout: defaulttProxyTimeout,
+	})
+	if category == "" {
+		category = CategoryHTTP
+	}
+	tp := new(textProxy)
+	tp.interval = interval
+	tp.ins = ins
+	tp.name = name
+	tp.urls = urls
+	tp.category = category
+	return tp
+}
+
+// Start start the crawler
+func (tp *textProxy) Start() {
+	tp.status = StatusRunning
+	for {
+		if tp.status != StatusRunning {
+			return
+		}
+		tp.fetch()
+		time.Sleep(tp.interval)
+	}
+}
+
+// fetch fetch all the proxy lists
+func (tp *textProxy) fetch() {
+	// 每个地址作为一页，每次均获取所有地址
+	tp.currentPage = 0
+	for _, item := range tp.urls {
+		data, err := tp.fetchData(item)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		for _, p := range ParseProxyList(data, tp.category) {
+			if tp.fetchListener == nil {
+				return
+			}
+			tp.emit(p)
+		}
+	}
+}
+
+// ParseProxyList parse the proxy of each line, the comment and malformed line will be skipped
+func ParseProxyList(data []byte, category string) []*Proxy {
+	list := make([]*Proxy, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 忽略空行与注释
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// 只取第一列，忽略后面的说明
+		line = strings.Fields(line)[0]
+		p, err := ParseProxy(line, category)
+		if err != nil {
+			logger.Debug("parse proxy fail",
+				zap.String("line", line),
+				zap.Error(err),
+			)
+			continue
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+// ParseProxy parse the proxy from ip:port or scheme://user:pass@ip:port,
+// the scheme is used as category, otherwise the category param is used
+func ParseProxy(value, category string) (p *Proxy, err error) {
+	if !strings.Contains(value, "://") {
+		value = category + "://" + value
+	}
+	info, err := url.Parse(value)
+	if err != nil {
+		return
+	}
+	category = strings.ToLower(info.Scheme)
+	switch category {
+	case CategoryHTTP, CategoryHTTPS, CategorySOCKS4, CategorySOCKS5:
+	default:
+		err = errInvalidProxy
+		return
+	}
+	ip := info.Hostname()
+	port := info.Port()
+	if net.ParseIP(ip) == nil {
+		err = errInvalidProxy
+		return
+	}
+	portValue, _ := strconv.Atoi(port)
+	if portValue <= 0 || portValue > 65535 {
+		err = errInvalidProxy
+		return
+	}
+	p = &Proxy{
+		IP:       ip,
+		Port:     port,
+		Category: category,
+	}
+	if info.User != nil {
+		p.Username = info.User.Username()
+		p.Password, _ = info.User.Password()
+	}
+	return
+}
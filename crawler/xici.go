@@ -45,6 +45,7 @@ func NewXiciProxy(interval time.Duration) *xiciProxy {
 		Timeout: defaulttProxyTimeout,
 	})
 	xiciProxy := new(xiciProxy)
+	xiciProxy.name = ProxyXiCi
 	xiciProxy.interval = interval
 	xiciProxy.ins = ins
 	return xiciProxy
@@ -65,7 +66,7 @@ func (xc *xiciProxy) Start() {
 
 // Fetch fetch proxy list from xici
 func (xc *xiciProxy) fetch() (err error) {
-	doc, err := xc.fetchPage("xici", "/%d")
+	doc, err := xc.fetchPage("/%d")
 	if err != nil || doc == nil {
 		return
 	}
@@ -100,7 +101,7 @@ func (xc *xiciProxy) fetch() (err error) {
 			xc.fetchListener == nil {
 			return
 		}
-		xc.fetchListener(&Proxy{
+		xc.emit(&Proxy{
 			IP:        ip,
 			Port:      port,
 			Anonymous: anonymous,
@@ -75,6 +75,9 @@ func TestXiciProxy(t *testing.T) {
 		assert.Equal("183.154.49.8", p.IP)
 		assert.Equal("9999", p.Port)
 		assert.Equal("http", p.Category)
+		assert.Equal(ProxyXiCi, p.Source)
+		assert.NotEqual(int64(0), p.FirstSeenAt)
+		assert.Equal(p.FirstSeenAt, p.LastSeenAt)
 		done <- true
 	})
 	go xici.Start()
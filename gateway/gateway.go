@@ -0,0 +1,323 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vicanso/proxy-pool/crawler"
+	"github.com/vicanso/proxy-pool/log"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultTimeout    = 30 * time.Second
+
+	// HeaderSession header of session key, the requests of the same session use the same proxy
+	HeaderSession = "X-Proxy-Session"
+	// HeaderSessionTTL header of session ttl, e.g.: 10m
+	HeaderSessionTTL = "X-Proxy-Session-Ttl"
+)
+
+var (
+	logger = log.Default()
+
+	// hopHeaders hop-by-hop headers, should not be forwarded
+	hopHeaders = []string{
+		"Connection",
+		"Proxy-Connection",
+		"Keep-Alive",
+		"Proxy-Authenticate",
+		"Proxy-Authorization",
+		"Te",
+		"Trailer",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+)
+
+type (
+	// Finder find an available proxy of the category
+	Finder func(category string, filters ...crawler.ProxyFilter) *crawler.Proxy
+	// Reporter report the outcome of the request through proxy
+	Reporter func(p *crawler.Proxy, outcome, host string)
+	// HostFilter create a filter to skip the proxies unavailable for the host
+	HostFilter func(host string) crawler.ProxyFilter
+	// SessionFinder find the proxy pinned by the session key, the categories are in order of preference
+	SessionFinder func(session string, ttl time.Duration, categories []string, filters ...crawler.ProxyFilter) *crawler.Proxy
+	// Gateway http proxy gateway, relay the request through the proxy pool
+	Gateway struct {
+		finder        Finder
+		sessionFinder SessionFinder
+		reporter      Reporter
+		hostFilter    HostFilter
+		token         string
+		timeout       time.Duration
+		maxRetries    int
+	}
+	// Config gateway config
+	Config struct {
+		// Finder find the upstream proxy
+		Finder Finder
+		// Reporter report the outcome of each request, it's optional
+		Reporter Reporter
+		// HostFilter skip the proxies unavailable for the target host, it's optional
+		HostFilter HostFilter
+		// SessionFinder find the proxy of the session header, it's optional
+		SessionFinder SessionFinder
+		// Token the client should set the token as password of Proxy-Authorization,
+		// if it's empty, the proxies which need authentication won't be used
+		Token string
+		// Timeout timeout of each request
+		Timeout time.Duration
+		// MaxRetries max retries, use a different upstream for each retry
+		MaxRetries int
+	}
+)
+
+// New create a new gateway
+func New(conf Config) *Gateway {
+	g := &Gateway{
+		finder:        conf.Finder,
+		sessionFinder: conf.SessionFinder,
+		reporter:      conf.Reporter,
+		hostFilter:    conf.HostFilter,
+		token:         conf.Token,
+		timeout:       conf.Timeout,
+		maxRetries:    conf.MaxRetries,
+	}
+	if g.timeout == 0 {
+		g.timeout = defaultTimeout
+	}
+	if g.maxRetries <= 0 {
+		g.maxRetries = defaultMaxRetries
+	}
+	return g
+}
+
+// removeHopHeaders remove hop-by-hop headers
+func removeHopHeaders(header http.Header) {
+	for _, key := range hopHeaders {
+		header.Del(key)
+	}
+}
+
+// copyHeader copy the header from src to dst
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
+
+// exclude create a filter to skip the proxies which have been tried
+func exclude(tried []*crawler.Proxy) crawler.ProxyFilter {
+	return func(p *crawler.Proxy) bool {
+		for _, item := range tried {
+			if item == p {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// withoutAuth skip the proxies which need authentication
+func withoutAuth(p *crawler.Proxy) bool {
+	return !p.HasAuth()
+}
+
+// isAuthorized test whether or not the request is authorized, Basic(the token
+// is used as password, the username is ignored) and Bearer are supported
+func (g *Gateway) isAuthorized(r *http.Request) bool {
+	if g.token == "" {
+		return true
+	}
+	value := r.Header.Get("Proxy-Authorization")
+	token := ""
+	if strings.HasPrefix(value, "Basic ") {
+		buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "Basic "))
+		if err != nil {
+			return false
+		}
+		arr := strings.SplitN(string(buf), ":", 2)
+		if len(arr) != 2 {
+			return false
+		}
+		token = arr[1]
+	} else if strings.HasPrefix(value, "Bearer ") {
+		token = strings.TrimPrefix(value, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(g.token)) == 1
+}
+
+// find find the upstream proxy of the category for the host, the tried proxies are skipped.
+// If the request has session header, the proxy pinned by the session is used, the https
+// proxy is preferred as it serves both http and https requests
+func (g *Gateway) find(r *http.Request, category, host string, tried []*crawler.Proxy) *crawler.Proxy {
+	filters := []crawler.ProxyFilter{
+		exclude(tried),
+	}
+	// 未设置token时任何人均可使用网关，不使用需要认证的代理，避免其账号被盗用
+	if g.token == "" {
+		filters = append(filters, withoutAuth)
+	}
+	if g.hostFilter != nil {
+		filters = append(filters, g.hostFilter(host))
+	}
+	session := r.Header.Get(HeaderSession)
+	if session != "" && g.sessionFinder != nil {
+		// 有效期格式不正确则使用默认值
+		ttl, _ := time.ParseDuration(r.Header.Get(HeaderSessionTTL))
+		// 会话的代理失败重试时，已尝试的代理被排除，会话绑定至新的代理。
+		// https的代理同样可转发http请求，因此会话优先绑定https的代理，
+		// 避免同一会话的http与https请求使用不同的出口IP，无https的代理时http请求才使用http的代理
+		categories := []string{
+			crawler.CategoryHTTPS,
+		}
+		if category == crawler.CategoryHTTP {
+			categories = append(categories, crawler.CategoryHTTP)
+		}
+		return g.sessionFinder(session, ttl, categories, filters...)
+	}
+	p := g.finder(category, filters...)
+	// 无可用的http代理时，使用https的代理转发
+	if p == nil && category == crawler.CategoryHTTP {
+		p = g.finder(crawler.CategoryHTTPS, filters...)
+	}
+	return p
+}
+
+// report report the outcome of the request through proxy
+func (g *Gateway) report(p *crawler.Proxy, host string, statusCode int, err error) {
+	if g.reporter == nil {
+		return
+	}
+	outcome := crawler.OutcomeSuccess
+	if err != nil {
+		outcome = crawler.OutcomeFailure
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			outcome = crawler.OutcomeTimeout
+		}
+	} else if statusCode == http.StatusForbidden ||
+		statusCode == http.StatusTooManyRequests {
+		// 目标网站返回禁止访问或请求过多，认为被其禁止
+		outcome = crawler.OutcomeBlocked
+	}
+	g.reporter(p, outcome, host)
+}
+
+// ServeHTTP relay the request through the upstream proxy
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !g.isAuthorized(r) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxy-pool"`)
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		g.serveConnect(w, r)
+		return
+	}
+	if r.URL.Host == "" {
+		http.Error(w, "only proxy request is supported", http.StatusBadRequest)
+		return
+	}
+	g.serveHTTP(w, r)
+}
+
+// serveHTTP forward the http request, retry with a different proxy if fail
+func (g *Gateway) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// 读取请求数据，以便重试时可再次发送
+	var body []byte
+	if r.Body != nil {
+		buf, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = buf
+	}
+
+	host := r.URL.Hostname()
+	tried := make([]*crawler.Proxy, 0, g.maxRetries)
+	for i := 0; i < g.maxRetries; i++ {
+		p := g.find(r, crawler.CategoryHTTP, host, tried)
+		if p == nil {
+			break
+		}
+		tried = append(tried, p)
+		transport := crawler.NewProxyTransport(p)
+		if transport == nil {
+			continue
+		}
+		resp, err := g.roundTrip(transport, r, body)
+		if err != nil {
+			g.report(p, host, 0, err)
+			transport.CloseIdleConnections()
+			logger.Error("forward request fail",
+				zap.String("proxy", p.Addr()),
+				zap.String("url", r.URL.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		g.report(p, host, resp.StatusCode, nil)
+		removeHopHeaders(resp.Header)
+		copyHeader(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		resp.Body.Close()
+		transport.CloseIdleConnections()
+		return
+	}
+	// 无可用的代理
+	if len(tried) == 0 {
+		http.Error(w, "no available proxy", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "all upstream proxies fail", http.StatusBadGateway)
+}
+
+// roundTrip send the request through the transport
+func (g *Gateway) roundTrip(transport http.RoundTripper, r *http.Request, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	copyHeader(req.Header, r.Header)
+	removeHopHeaders(req.Header)
+	// 会话的请求头只用于网关，不转发
+	req.Header.Del(HeaderSession)
+	req.Header.Del(HeaderSessionTTL)
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   g.timeout,
+		// 代理不处理跳转，由客户端自行处理
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return client.Do(req.WithContext(r.Context()))
+}
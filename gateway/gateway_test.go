@@ -0,0 +1,385 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/proxy-pool/crawler"
+)
+
+func newTestProxy(addr, category string) *crawler.Proxy {
+	host, port, _ := net.SplitHostPort(addr)
+	return &crawler.Proxy{
+		IP:       host,
+		Port:     port,
+		Category: category,
+	}
+}
+
+func newTestFinder(pl *crawler.ProxyList) Finder {
+	return func(category string, filters ...crawler.ProxyFilter) *crawler.Proxy {
+		return pl.FindOne(category, -1, filters...)
+	}
+}
+
+// newClosedAddr get an address which refuses connection
+func newClosedAddr() string {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestGatewayHTTP(t *testing.T) {
+	assert := assert.New(t)
+
+	// 上游代理，返回接收到的请求地址
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "true")
+		_, _ = w.Write([]byte(r.URL.String()))
+	}))
+	defer upstream.Close()
+
+	pl := new(crawler.ProxyList)
+	pl.Add(newTestProxy(newClosedAddr(), "http"))
+	pl.Add(newTestProxy(upstream.Listener.Addr().String(), "http"))
+	g := New(Config{
+		Finder:     newTestFinder(pl),
+		MaxRetries: 2,
+	})
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		},
+	}
+	resp, err := client.Get("http://example.com/test?a=1")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	buf, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("true", resp.Header.Get("X-Upstream"))
+	assert.Equal("http://example.com/test?a=1", string(buf))
+}
+
+func TestGatewayHTTPFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.String()))
+	}))
+	defer upstream.Close()
+
+	// 只有https的代理，http请求同样可使用
+	pl := new(crawler.ProxyList)
+	pl.Add(newTestProxy(upstream.Listener.Addr().String(), "https"))
+	g := New(Config{
+		Finder: newTestFinder(pl),
+	})
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		},
+	}
+	resp, err := client.Get("http://example.com/")
+	assert.Nil(err)
+	defer resp.Body.Close()
+	buf, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("http://example.com/", string(buf))
+}
+
+func TestGatewayReport(t *testing.T) {
+	assert := assert.New(t)
+
+	// 上游代理，目标网站禁止访问
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer upstream.Close()
+
+	pl := new(crawler.ProxyList)
+	closedProxy := newTestProxy(newClosedAddr(), "http")
+	pl.Add(closedProxy)
+	pl.Add(newTestProxy(upstream.Listener.Addr().String(), "http"))
+	outcomes := make(map[string]string)
+	hosts := make([]string, 0)
+	g := New(Config{
+		Finder: newTestFinder(pl),
+		Reporter: func(p *crawler.Proxy, outcome, host string) {
+			assert.Equal("example.com", host)
+			outcomes[p.Addr()] = outcome
+		},
+		HostFilter: func(host string) crawler.ProxyFilter {
+			hosts = append(hosts, host)
+			return func(_ *crawler.Proxy) bool {
+				return true
+			}
+		},
+		MaxRetries: 2,
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com:8080/", nil)
+	resp := httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusForbidden, resp.Code)
+	assert.Equal(crawler.OutcomeBlocked, outcomes[upstream.Listener.Addr().String()])
+	if _, tried := outcomes[closedProxy.Addr()]; tried {
+		assert.Equal(crawler.OutcomeFailure, outcomes[closedProxy.Addr()])
+	}
+	assert.Equal("example.com", hosts[0])
+}
+
+func TestGatewaySession(t *testing.T) {
+	assert := assert.New(t)
+
+	// 上游代理，返回是否收到会话的请求头
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get(HeaderSession)))
+	}))
+	defer upstream.Close()
+
+	pl := new(crawler.ProxyList)
+	pl.Add(newTestProxy(upstream.Listener.Addr().String(), "http"))
+	var session string
+	var ttl time.Duration
+	g := New(Config{
+		Finder: func(_ string, _ ...crawler.ProxyFilter) *crawler.Proxy {
+			return nil
+		},
+		SessionFinder: func(key string, d time.Duration, categories []string, filters ...crawler.ProxyFilter) *crawler.Proxy {
+			session = key
+			ttl = d
+			return pl.Find(crawler.FindOptions{
+				Categories: categories,
+				Speed:      -1,
+			}, filters...)
+		},
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set(HeaderSession, "abc")
+	req.Header.Set(HeaderSessionTTL, "5m")
+	resp := httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("abc", session)
+	assert.Equal(5*time.Minute, ttl)
+	// 会话请求头不转发
+	assert.Equal("", resp.Body.String())
+
+	// http的请求优先使用https的代理，https的请求只使用https的代理
+	var categories [][]string
+	g.sessionFinder = func(key string, d time.Duration, list []string, filters ...crawler.ProxyFilter) *crawler.Proxy {
+		categories = append(categories, list)
+		return newTestProxy(upstream.Listener.Addr().String(), list[0])
+	}
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set(HeaderSession, "abc")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest("CONNECT", "http://example.com:443", nil)
+	req.Header.Set(HeaderSession, "abc")
+	assert.NotNil(g.find(req, crawler.CategoryHTTPS, "example.com", nil))
+	assert.Equal([][]string{
+		{
+			crawler.CategoryHTTPS,
+			crawler.CategoryHTTP,
+		},
+		{
+			crawler.CategoryHTTPS,
+		},
+	}, categories)
+}
+
+func TestGatewayNoProxy(t *testing.T) {
+	assert := assert.New(t)
+	pl := new(crawler.ProxyList)
+	g := New(Config{
+		Finder: newTestFinder(pl),
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	resp := httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusServiceUnavailable, resp.Code)
+
+	pl.Add(newTestProxy(newClosedAddr(), "http"))
+	resp = httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusBadGateway, resp.Code)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.URL.Host = ""
+	resp = httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusBadRequest, resp.Code)
+}
+
+// newTunnelProxy create a proxy server which supports CONNECT
+func newTunnelProxy(refused bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || refused {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(target, conn)
+			target.Close()
+		}()
+		_, _ = io.Copy(conn, target)
+		conn.Close()
+	}))
+}
+
+func TestGatewayConnect(t *testing.T) {
+	assert := assert.New(t)
+
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer target.Close()
+
+	refused := newTunnelProxy(true)
+	defer refused.Close()
+	accepted := newTunnelProxy(false)
+	defer accepted.Close()
+
+	pl := new(crawler.ProxyList)
+	pl.Add(newTestProxy(refused.Listener.Addr().String(), "https"))
+	pl.Add(newTestProxy(accepted.Listener.Addr().String(), "https"))
+	// http类型的代理不会用于隧道
+	pl.Add(newTestProxy(newClosedAddr(), "http"))
+	g := New(Config{
+		Finder:     newTestFinder(pl),
+		MaxRetries: 2,
+	})
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(server.URL)
+	transport := target.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{
+		Transport: transport,
+	}
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(target.URL)
+		assert.Nil(err)
+		buf, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal("hello", string(buf))
+		transport.CloseIdleConnections()
+	}
+
+	// 所有代理均拒绝隧道
+	pl.Remove(newTestProxy(accepted.Listener.Addr().String(), "https"))
+	_, err := client.Get(target.URL)
+	assert.NotNil(err)
+}
+
+func TestGatewayAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 网关的认证信息不转发，使用上游代理的账号密码
+		user, pass, _ := (&http.Request{
+			Header: http.Header{
+				"Authorization": r.Header["Proxy-Authorization"],
+			},
+		}).BasicAuth()
+		assert.Equal("user", user)
+		assert.Equal("pass", pass)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	pl := new(crawler.ProxyList)
+	p := newTestProxy(upstream.Listener.Addr().String(), "http")
+	p.Username = "user"
+	p.Password = "pass"
+	pl.Add(p)
+
+	// 未设置token时不使用需要认证的代理
+	g := New(Config{
+		Finder: newTestFinder(pl),
+	})
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	resp := httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusServiceUnavailable, resp.Code)
+
+	g = New(Config{
+		Finder: newTestFinder(pl),
+		Token:  "token",
+	})
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	resp = httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusProxyAuthRequired, resp.Code)
+	assert.NotEmpty(resp.Header().Get("Proxy-Authenticate"))
+
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.SetBasicAuth("any", "invalid")
+	req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	resp = httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusProxyAuthRequired, resp.Code)
+
+	// 通过客户端设置代理的账号密码认证
+	server := httptest.NewServer(g)
+	defer server.Close()
+	proxyURL, _ := url.Parse(server.URL)
+	proxyURL.User = url.UserPassword("any", "token")
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		},
+	}
+	res, err := client.Get("http://example.com/")
+	assert.Nil(err)
+	defer res.Body.Close()
+	buf, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("ok", string(buf))
+
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.Header.Set("Proxy-Authorization", "Bearer token")
+	resp = httptest.NewRecorder()
+	g.ServeHTTP(resp, req)
+	assert.Equal(http.StatusOK, resp.Code)
+}
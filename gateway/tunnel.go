@@ -0,0 +1,144 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/vicanso/proxy-pool/crawler"
+	"go.uber.org/zap"
+)
+
+// dialTunnel connect to the upstream proxy and send CONNECT request,
+// the connection is returned when the upstream accepts the tunnel
+func (g *Gateway) dialTunnel(p *crawler.Proxy, host string) (conn net.Conn, br *bufio.Reader, err error) {
+	conn, err = net.DialTimeout("tcp", p.Addr(), g.timeout)
+	if err != nil {
+		return
+	}
+	// 握手阶段设置超时，建立隧道后则取消
+	_ = conn.SetDeadline(time.Now().Add(g.timeout))
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL: &url.URL{
+			Opaque: host,
+		},
+		Host:   host,
+		Header: make(http.Header),
+	}
+	// 需要认证的代理
+	if p.HasAuth() {
+		auth := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	br = bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		err = fmt.Errorf("upstream refuses the tunnel, status:%d", resp.StatusCode)
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return
+}
+
+// serveConnect create a tunnel through the upstream https proxy,
+// retry with a different proxy if the handshake fails
+func (g *Gateway) serveConnect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking is not supported", http.StatusInternalServerError)
+		return
+	}
+	host := r.Host
+	hostname := r.URL.Hostname()
+	tried := make([]*crawler.Proxy, 0, g.maxRetries)
+	var upstream net.Conn
+	var upstreamReader *bufio.Reader
+	for i := 0; i < g.maxRetries; i++ {
+		// 隧道只使用支持https的代理
+		p := g.find(r, crawler.CategoryHTTPS, hostname, tried)
+		if p == nil {
+			break
+		}
+		tried = append(tried, p)
+		conn, br, err := g.dialTunnel(p, host)
+		// 隧道中为加密的数据，只能根据是否建立成功反馈
+		g.report(p, hostname, 0, err)
+		if err != nil {
+			logger.Error("create tunnel fail",
+				zap.String("proxy", p.Addr()),
+				zap.String("host", host),
+				zap.Error(err),
+			)
+			continue
+		}
+		upstream = conn
+		upstreamReader = br
+		break
+	}
+	if upstream == nil {
+		if len(tried) == 0 {
+			http.Error(w, "no available proxy", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "all upstream proxies fail", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	client, clientReader, err := hijacker.Hijack()
+	if err != nil {
+		logger.Error("hijack fail",
+			zap.Error(err),
+		)
+		return
+	}
+	defer client.Close()
+	_, err = client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	if err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	// 两个方向的数据转发，需要将已读取至缓存的数据一并转发
+	go func() {
+		_, _ = io.Copy(upstream, clientReader)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, upstreamReader)
+		done <- struct{}{}
+	}()
+	// 任一方向结束则关闭隧道
+	<-done
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/vicanso/proxy-pool/crawler"
+)
+
+type (
+	// Resolver geo resolver of mmdb files
+	Resolver struct {
+		city *maxminddb.Reader
+		asn  *maxminddb.Reader
+	}
+	cityRecord struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+	}
+	asnRecord struct {
+		Number       uint   `maxminddb:"autonomous_system_number"`
+		Organization string `maxminddb:"autonomous_system_organization"`
+	}
+)
+
+// New create a new resolver, the file is country or city database
+// and the asnFile is asn database, either of them can be empty
+func New(file, asnFile string) (r *Resolver, err error) {
+	r = new(Resolver)
+	if file != "" {
+		r.city, err = maxminddb.Open(file)
+		if err != nil {
+			return
+		}
+	}
+	if asnFile != "" {
+		r.asn, err = maxminddb.Open(asnFile)
+		if err != nil {
+			r.Close()
+			return
+		}
+	}
+	return
+}
+
+// Resolve get the geo info of ip
+func (r *Resolver) Resolve(ip net.IP) (info *crawler.GeoInfo, err error) {
+	info = new(crawler.GeoInfo)
+	if r.city != nil {
+		record := cityRecord{}
+		err = r.city.Lookup(ip, &record)
+		if err != nil {
+			return
+		}
+		info.Country = record.Country.ISOCode
+		info.City = record.City.Names["en"]
+	}
+	if r.asn != nil {
+		record := asnRecord{}
+		err = r.asn.Lookup(ip, &record)
+		if err != nil {
+			return
+		}
+		info.ASN = record.Number
+		info.ASOrg = record.Organization
+	}
+	return
+}
+
+// Close close the database files
+func (r *Resolver) Close() error {
+	var err error
+	if r.city != nil {
+		err = r.city.Close()
+	}
+	if r.asn != nil {
+		e := r.asn.Close()
+		if e != nil {
+			err = e
+		}
+	}
+	return err
+}
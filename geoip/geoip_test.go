@@ -0,0 +1,188 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeControl encode the control byte of mmdb data, only the size less than 285 is supported
+func encodeControl(buf *bytes.Buffer, dataType, size int) {
+	sizeBits := size
+	if size >= 29 {
+		sizeBits = 29
+	}
+	if dataType > 7 {
+		buf.WriteByte(byte(sizeBits))
+		buf.WriteByte(byte(dataType - 7))
+	} else {
+		buf.WriteByte(byte(dataType<<5 | sizeBits))
+	}
+	if size >= 29 {
+		buf.WriteByte(byte(size - 29))
+	}
+}
+
+// encodeData encode the value as mmdb data, only string, uint and map are supported
+func encodeData(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		encodeControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case uint32:
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, v)
+		encodeControl(buf, 6, 4)
+		buf.Write(data)
+	case uint16:
+		data := make([]byte, 2)
+		binary.BigEndian.PutUint16(data, v)
+		encodeControl(buf, 5, 2)
+		buf.Write(data)
+	case []interface{}:
+		encodeControl(buf, 11, len(v))
+		for _, item := range v {
+			encodeData(buf, item)
+		}
+	case map[string]interface{}:
+		encodeControl(buf, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encodeData(buf, key)
+			encodeData(buf, v[key])
+		}
+	}
+}
+
+// writeTestDB write an ipv4 mmdb file of the ip and data(record size 24)
+func writeTestDB(file string, records map[string]map[string]interface{}) error {
+	// 每个节点的左右记录，-1表示无数据，小于-1表示数据(-2-index)
+	nodes := [][2]int{{-1, -1}}
+	dataList := make([]map[string]interface{}, 0)
+	for ip, data := range records {
+		dataList = append(dataList, data)
+		dataIndex := -2 - (len(dataList) - 1)
+		ipv4 := net.ParseIP(ip).To4()
+		node := 0
+		for i := 0; i < 32; i++ {
+			bit := int(ipv4[i/8]>>(7-uint(i%8))) & 1
+			if i == 31 {
+				nodes[node][bit] = dataIndex
+				break
+			}
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	nodeCount := len(nodes)
+	dataBuf := new(bytes.Buffer)
+	offsets := make([]int, len(dataList))
+	for i, data := range dataList {
+		offsets[i] = dataBuf.Len()
+		encodeData(dataBuf, data)
+	}
+	buf := new(bytes.Buffer)
+	for _, node := range nodes {
+		for _, record := range node {
+			value := record
+			if record == -1 {
+				value = nodeCount
+			} else if record < -1 {
+				value = nodeCount + 16 + offsets[-2-record]
+			}
+			buf.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(dataBuf.Bytes())
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeData(buf, map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               "Test",
+		"languages":                   []interface{}{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint32(0),
+		"description":                 map[string]interface{}{},
+	})
+	return ioutil.WriteFile(file, buf.Bytes(), 0600)
+}
+
+func TestResolver(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "geoip")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	cityFile := dir + "/city.mmdb"
+	err = writeTestDB(cityFile, map[string]map[string]interface{}{
+		"1.1.1.1": {
+			"country": map[string]interface{}{
+				"iso_code": "AU",
+			},
+			"city": map[string]interface{}{
+				"names": map[string]interface{}{
+					"en": "Sydney",
+				},
+			},
+		},
+	})
+	assert.Nil(err)
+	asnFile := dir + "/asn.mmdb"
+	err = writeTestDB(asnFile, map[string]map[string]interface{}{
+		"1.1.1.1": {
+			"autonomous_system_number":       uint32(13335),
+			"autonomous_system_organization": "Cloudflare",
+		},
+	})
+	assert.Nil(err)
+
+	r, err := New(cityFile, asnFile)
+	assert.Nil(err)
+	defer r.Close()
+
+	info, err := r.Resolve(net.ParseIP("1.1.1.1"))
+	assert.Nil(err)
+	assert.Equal("AU", info.Country)
+	assert.Equal("Sydney", info.City)
+	assert.Equal(uint(13335), info.ASN)
+	assert.Equal("Cloudflare", info.ASOrg)
+
+	// 不在数据库中的IP
+	info, err = r.Resolve(net.ParseIP("2.2.2.2"))
+	assert.Nil(err)
+	assert.Equal("", info.Country)
+	assert.Equal(uint(0), info.ASN)
+
+	_, err = New(dir+"/not-found.mmdb", "")
+	assert.NotNil(err)
+}
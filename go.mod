@@ -4,10 +4,17 @@ go 1.14
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/alicebob/miniredis/v2 v2.11.4
+	github.com/go-redis/redis/v7 v7.4.0
 	github.com/gobuffalo/packr/v2 v2.8.0
+	github.com/oschwald/maxminddb-golang v1.6.0
+	github.com/prometheus/client_golang v1.5.1
+	github.com/prometheus/common v0.9.1
 	github.com/spf13/viper v1.6.3
 	github.com/stretchr/testify v1.5.1
 	github.com/vicanso/elton v0.5.0
 	github.com/vicanso/go-axios v0.1.0
+	github.com/vicanso/hes v0.2.1
+	go.etcd.io/bbolt v1.3.4
 	go.uber.org/zap v1.14.1
 )
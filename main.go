@@ -1,15 +1,59 @@
 package main
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/vicanso/elton"
 	"github.com/vicanso/elton/middleware"
+	"github.com/vicanso/hes"
 	"github.com/vicanso/proxy-pool/config"
 	_ "github.com/vicanso/proxy-pool/controller"
+	"github.com/vicanso/proxy-pool/crawler"
+	"github.com/vicanso/proxy-pool/gateway"
 	"github.com/vicanso/proxy-pool/log"
+	"github.com/vicanso/proxy-pool/metrics"
 	"github.com/vicanso/proxy-pool/router"
+	"github.com/vicanso/proxy-pool/service"
 	"go.uber.org/zap"
 )
 
+// startGateway start the proxy gateway
+func startGateway() {
+	logger := log.Default()
+	conf := config.GetGateway()
+	if conf.Listen == "" {
+		return
+	}
+	g := gateway.New(gateway.Config{
+		Finder: func(category string, filters ...crawler.ProxyFilter) *crawler.Proxy {
+			return service.GetAvailableProxy(category, -1, filters...)
+		},
+		// 网关的请求结果同样反馈至代理池
+		Reporter: func(p *crawler.Proxy, outcome, host string) {
+			_ = service.ReportProxy(p, outcome, host)
+		},
+		HostFilter: service.NewDomainFilter,
+		SessionFinder: func(session string, ttl time.Duration, categories []string, filters ...crawler.ProxyFilter) *crawler.Proxy {
+			return service.FindSessionProxy(session, ttl, crawler.FindOptions{
+				Categories: categories,
+				Speed:      -1,
+			}, filters...)
+		},
+		// 客户端需要使用与接口相同的token认证
+		Token:      config.GetAuthToken(),
+		Timeout:    conf.Timeout,
+		MaxRetries: conf.MaxRetries,
+	})
+	logger.Info("start to linstening gateway...",
+		zap.String("listen", conf.Listen),
+	)
+	err := http.ListenAndServe(conf.Listen, g)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func main() {
 	logger := log.Default()
 	e := elton.New()
@@ -18,9 +62,26 @@ func main() {
 		c.NoCache()
 		return c.Next()
 	})
+	// 记录各路由的请求数（包括204等无数据的响应）
+	e.Use(func(c *elton.Context) error {
+		err := c.Next()
+		status := c.StatusCode
+		if err != nil {
+			status = hes.Wrap(err).StatusCode
+		}
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.IncRequest(c.Route, status)
+		return err
+	})
 	e.Use(middleware.NewDefaultResponder())
+	e.Use(middleware.NewDefaultBodyParser())
 
 	router.Init(e)
+
+	go startGateway()
+
 	addr := config.GetListenAddr()
 	logger.Info("start to linstening...",
 		zap.String("listen", addr),
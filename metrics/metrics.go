@@ -0,0 +1,121 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// ContentType content type of the metrics
+	ContentType = string(expfmt.FmtText)
+
+	namespace = "proxy_pool"
+
+	resultSuccess = "success"
+	resultFail    = "fail"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	detectDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "detect_duration_seconds",
+		Help:      "Duration of proxy detection.",
+		Buckets:   []float64{0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10},
+	}, []string{"result"})
+	crawlerFetchTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "crawler_fetch_total",
+		Help:      "Count of crawler fetches.",
+	}, []string{"source", "result"})
+	reportTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_total",
+		Help:      "Count of proxy reports from client.",
+	}, []string{"outcome"})
+	requestTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Count of api requests.",
+	}, []string{"route", "status"})
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		detectDuration,
+		crawlerFetchTotal,
+		reportTotal,
+		requestTotal,
+	)
+}
+
+// getResult get the result label
+func getResult(success bool) string {
+	if success {
+		return resultSuccess
+	}
+	return resultFail
+}
+
+// ObserveDetect observe the duration and result of proxy detection
+func ObserveDetect(d time.Duration, available bool) {
+	detectDuration.WithLabelValues(getResult(available)).Observe(d.Seconds())
+}
+
+// IncCrawlerFetch increase the fetch count of crawler
+func IncCrawlerFetch(source string, success bool) {
+	crawlerFetchTotal.WithLabelValues(source, getResult(success)).Inc()
+}
+
+// IncReport increase the report count of outcome
+func IncReport(outcome string) {
+	reportTotal.WithLabelValues(outcome).Inc()
+}
+
+// IncRequest increase the request count of api
+func IncRequest(route string, status int) {
+	requestTotal.WithLabelValues(route, strconv.Itoa(status)).Inc()
+}
+
+// MustRegister register the collectors, it panics if any error occurs
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// Gather gather the metrics as prometheus text format
+func Gather() (buf *bytes.Buffer, err error) {
+	families, err := registry.Gather()
+	if err != nil {
+		return
+	}
+	buf = new(bytes.Buffer)
+	encoder := expfmt.NewEncoder(buf, expfmt.FmtText)
+	for _, mf := range families {
+		err = encoder.Encode(mf)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
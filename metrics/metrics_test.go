@@ -0,0 +1,39 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGather(t *testing.T) {
+	assert := assert.New(t)
+	IncCrawlerFetch("xici", true)
+	IncCrawlerFetch("xici", false)
+	ObserveDetect(time.Second, true)
+	IncRequest("/proxies/one", http.StatusNoContent)
+
+	buf, err := Gather()
+	assert.Nil(err)
+	data := buf.String()
+	assert.Contains(data, `proxy_pool_crawler_fetch_total{result="success",source="xici"} 1`)
+	assert.Contains(data, `proxy_pool_crawler_fetch_total{result="fail",source="xici"} 1`)
+	assert.Contains(data, `proxy_pool_detect_duration_seconds_count{result="success"} 1`)
+	assert.Contains(data, `proxy_pool_http_requests_total{route="/proxies/one",status="204"} 1`)
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vicanso/proxy-pool/crawler"
+	"github.com/vicanso/proxy-pool/metrics"
+)
+
+type (
+	// poolCollector collect the size of proxy pool when scraping
+	poolCollector struct {
+		c              *crawler.Crawler
+		availableDesc  *prometheus.Desc
+		newProxiesDesc *prometheus.Desc
+	}
+)
+
+func init() {
+	metrics.MustRegister(newPoolCollector(defaultCrawler))
+}
+
+// newPoolCollector create a new collector of proxy pool
+func newPoolCollector(c *crawler.Crawler) *poolCollector {
+	return &poolCollector{
+		c: c,
+		availableDesc: prometheus.NewDesc(
+			"proxy_pool_available_proxies",
+			"Count of available proxies.",
+			[]string{"category", "speed"},
+			nil,
+		),
+		newProxiesDesc: prometheus.NewDesc(
+			"proxy_pool_new_proxies",
+			"Count of new proxies waiting for detection.",
+			nil,
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (pc *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.availableDesc
+	ch <- pc.newProxiesDesc
+}
+
+// Collect implements prometheus.Collector
+func (pc *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := make(map[[2]string]int)
+	// 保证各类型均有数据，方便对可用代理为0时告警
+	for _, category := range []string{
+		crawler.CategoryHTTP,
+		crawler.CategoryHTTPS,
+		crawler.CategorySOCKS4,
+		crawler.CategorySOCKS5,
+	} {
+		counts[[2]string{category, "0"}] = 0
+	}
+	for _, p := range pc.c.GetAvailableProxyList() {
+		speed := strconv.Itoa(int(atomic.LoadInt32(&p.Speed)))
+		counts[[2]string{p.Category, speed}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			pc.availableDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			key[0],
+			key[1],
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(
+		pc.newProxiesDesc,
+		prometheus.GaugeValue,
+		float64(pc.c.GetNewProxyCount()),
+	)
+}
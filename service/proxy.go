@@ -16,17 +16,104 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/vicanso/proxy-pool/config"
 	"github.com/vicanso/proxy-pool/crawler"
+	"github.com/vicanso/proxy-pool/geoip"
+	"github.com/vicanso/proxy-pool/store"
+)
+
+const (
+	crawlerTypeJSON = "json"
+	crawlerTypeText = "text"
+
+	storeBolt  = "bolt"
+	storeRedis = "redis"
 )
 
 var (
 	defaultCrawler = new(crawler.Crawler)
+	selectConfig   = config.GetSelect()
+	sessionConfig  = config.GetSession()
+	leaseConfig    = config.GetLease()
 )
 
+// newGenericCrawler create a generic crawler for the website which isn't built in
+func newGenericCrawler(item *config.Crawler) crawler.ProxyCrawler {
+	interval := item.Interval
+	switch item.Type {
+	case crawlerTypeText:
+		return crawler.NewTextProxy(item.Name, interval, item.URLs, item.Category)
+	case crawlerTypeJSON:
+		jp := crawler.NewJSONProxy(interval, crawler.JSONProxyConfig{
+			Name:             item.Name,
+			BaseURL:          item.BaseURL,
+			PageURL:          item.PageURL,
+			PageParam:        item.PageParam,
+			CursorPath:       item.CursorPath,
+			CursorParam:      item.CursorParam,
+			ListPath:         item.ListPath,
+			IPPath:           item.IPPath,
+			PortPath:         item.PortPath,
+			CategoryPath:     item.CategoryPath,
+			AnonymityPath:    item.AnonymityPath,
+			AnonymityKeyword: item.AnonymityKeyword,
+			Category:         item.Category,
+		})
+		jp.LimitMaxPage(item.MaxPage)
+		return jp
+	}
+	// 默认使用通用的表格抓取
+	tp := crawler.NewTableProxy(interval, crawler.TableProxyConfig{
+		Name:             item.Name,
+		BaseURL:          item.BaseURL,
+		PageURL:          item.PageURL,
+		PageSelector:     item.PageSelector,
+		RowSelector:      item.RowSelector,
+		IPIndex:          item.IPIndex,
+		PortIndex:        item.PortIndex,
+		CategoryIndex:    item.CategoryIndex,
+		AnonymityIndex:   item.AnonymityIndex,
+		AnonymityKeyword: item.AnonymityKeyword,
+		Category:         item.Category,
+	})
+	tp.LimitMaxPage(item.MaxPage)
+	return tp
+}
+
+// newProxyStore create a new proxy store
+func newProxyStore(conf *config.Store) (crawler.ProxyStore, error) {
+	switch conf.Type {
+	case "":
+		return nil, nil
+	case storeBolt:
+		bs, err := store.NewBoltStore(conf.Path)
+		if err != nil {
+			return nil, err
+		}
+		return bs, nil
+	case storeRedis:
+		rs, err := store.NewRedisStore(store.RedisOptions{
+			Addr:     conf.Addr,
+			Password: conf.Password,
+			DB:       conf.DB,
+			Prefix:   conf.Prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return rs, nil
+	default:
+		return nil, fmt.Errorf("store type(%s) is not supported", conf.Type)
+	}
+}
+
 func init() {
+	if !crawler.IsValidStrategy(selectConfig.Strategy) {
+		panic(fmt.Errorf("select strategy(%s) is invalid", selectConfig.Strategy))
+	}
 	crawlerProxyList := make([]crawler.ProxyCrawler, 0)
 	for _, item := range config.GetCrawlers() {
 		interval := item.Interval
@@ -40,16 +127,49 @@ func init() {
 			kuai := crawler.NewKuaiProxy(interval)
 			kuai.LimitMaxPage(item.MaxPage)
 			c = kuai
-		default:
+		case crawler.ProxyXiCi:
 			xici := crawler.NewXiciProxy(interval)
 			xici.LimitMaxPage(item.MaxPage)
 			c = xici
+		default:
+			c = newGenericCrawler(item)
 		}
 		crawlerProxyList = append(crawlerProxyList, c)
 	}
+	seedConfig := config.GetSeed()
+	if len(seedConfig.List) != 0 || seedConfig.File != "" {
+		crawlerProxyList = append(crawlerProxyList, crawler.NewSeedProxy(seedConfig.Interval, seedConfig.List, seedConfig.File))
+	}
 	if len(crawlerProxyList) == 0 {
 		panic(errors.New("no proxy crawler"))
 	}
+	storeConfig := config.GetStore()
+	proxyStore, err := newProxyStore(storeConfig)
+	if err != nil {
+		panic(err)
+	}
+	if proxyStore != nil {
+		err = defaultCrawler.SetStore(proxyStore)
+		if err != nil {
+			panic(err)
+		}
+		// 定时从存储中重新加载，与其它实例同步
+		if storeConfig.SyncInterval > 0 {
+			go func() {
+				for range time.NewTicker(storeConfig.SyncInterval).C {
+					_ = defaultCrawler.SyncAvailableProxy()
+				}
+			}()
+		}
+	}
+	geoConfig := config.GetGeoIP()
+	if geoConfig.File != "" || geoConfig.ASNFile != "" {
+		resolver, err := geoip.New(geoConfig.File, geoConfig.ASNFile)
+		if err != nil {
+			panic(err)
+		}
+		defaultCrawler.SetGeoResolver(resolver)
+	}
 	defaultCrawler.Start(crawlerProxyList...)
 	go func() {
 		detectConfig := config.GetDetect()
@@ -60,11 +180,73 @@ func init() {
 }
 
 // GetAvailableProxyList get available proxy lsit
-func GetAvailableProxyList() []*crawler.Proxy {
-	return defaultCrawler.GetAvailableProxyList()
+func GetAvailableProxyList(filters ...crawler.ProxyFilter) []*crawler.Proxy {
+	return defaultCrawler.GetAvailableProxyList(filters...)
+}
+
+// GetAvailableProxy get available proxy, the default strategy is used
+func GetAvailableProxy(category string, speed int, filters ...crawler.ProxyFilter) *crawler.Proxy {
+	return FindAvailableProxy(crawler.FindOptions{
+		Category: category,
+		Speed:    int32(speed),
+	}, filters...)
+}
+
+// FindAvailableProxy find available proxy matches the options,
+// the default strategy is used if the strategy of options is empty
+func FindAvailableProxy(opts crawler.FindOptions, filters ...crawler.ProxyFilter) *crawler.Proxy {
+	if opts.Strategy == "" {
+		opts.Strategy = selectConfig.Strategy
+	}
+	return defaultCrawler.FindAvailableProxy(opts, filters...)
+}
+
+// FindSessionProxy find the proxy pinned by the session key, the ttl is limited by
+// the max ttl of config, and the default ttl is used if it's 0
+func FindSessionProxy(key string, ttl time.Duration, opts crawler.FindOptions, filters ...crawler.ProxyFilter) *crawler.Proxy {
+	if ttl <= 0 {
+		ttl = sessionConfig.TTL
+	}
+	if ttl > sessionConfig.MaxTTL {
+		ttl = sessionConfig.MaxTTL
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = selectConfig.Strategy
+	}
+	return defaultCrawler.FindSessionProxy(key, ttl, opts, filters...)
+}
+
+// LeaseProxy check out one proxy exclusively, the ttl is limited by
+// the max ttl of config, and the default ttl is used if it's 0
+func LeaseProxy(ttl time.Duration, opts crawler.FindOptions, filters ...crawler.ProxyFilter) (*crawler.Lease, error) {
+	if ttl <= 0 {
+		ttl = leaseConfig.TTL
+	}
+	if ttl > leaseConfig.MaxTTL {
+		ttl = leaseConfig.MaxTTL
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = selectConfig.Strategy
+	}
+	return defaultCrawler.LeaseProxy(ttl, opts, filters...)
+}
+
+// ReleaseProxy release the leased proxy with the outcome of the usage
+func ReleaseProxy(id, outcome, host string) error {
+	return defaultCrawler.ReleaseProxy(id, outcome, host)
+}
+
+// ReportProxy report the outcome of the request through proxy
+func ReportProxy(p *crawler.Proxy, outcome, host string) error {
+	return defaultCrawler.Report(p, outcome, host)
+}
+
+// NewDomainFilter create a filter to skip the proxies fail for the domain recently
+func NewDomainFilter(domain string) crawler.ProxyFilter {
+	return defaultCrawler.NewDomainFilter(domain)
 }
 
-// GetAvailableProxy get available proxy
-func GetAvailableProxy(category string, speed int) *crawler.Proxy {
-	return defaultCrawler.GetAvailableProxy(category, int32(speed))
+// GetSourceStats get the yield stats of proxy sources
+func GetSourceStats() []*crawler.SourceStats {
+	return crawler.GetSourceStats()
 }
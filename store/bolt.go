@@ -0,0 +1,109 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vicanso/proxy-pool/crawler"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	proxyBucket = []byte("proxies")
+)
+
+type (
+	// BoltStore bolt store, save the proxies to local file
+	BoltStore struct {
+		db *bolt.DB
+	}
+)
+
+// NewBoltStore create a new bolt store
+func NewBoltStore(file string) (*BoltStore, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{
+		Timeout: 3 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(proxyBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{
+		db: db,
+	}, nil
+}
+
+// Load load all proxies from bolt
+func (bs *BoltStore) Load() (list []*crawler.Proxy, err error) {
+	list = make([]*crawler.Proxy, 0)
+	err = bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(proxyBucket).ForEach(func(_, v []byte) error {
+			p := new(crawler.Proxy)
+			err := json.Unmarshal(v, p)
+			if err != nil {
+				return err
+			}
+			list = append(list, p)
+			return nil
+		})
+	})
+	return
+}
+
+// Save save the proxies to bolt
+func (bs *BoltStore) Save(list ...*crawler.Proxy) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proxyBucket)
+		for _, p := range list {
+			buf, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(p.Key()), buf)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove remove the proxies from bolt
+func (bs *BoltStore) Remove(list ...*crawler.Proxy) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proxyBucket)
+		for _, p := range list {
+			err := b.Delete([]byte(p.Key()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close close the bolt db
+func (bs *BoltStore) Close() error {
+	return bs.db.Close()
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/proxy-pool/crawler"
+)
+
+func TestBoltStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "proxy-pool")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "proxy.db")
+
+	bs, err := NewBoltStore(file)
+	assert.Nil(err)
+	p := &crawler.Proxy{
+		IP:         "127.0.0.1",
+		Port:       "80",
+		Category:   "http",
+		DetectedAt: 1576300000,
+		Speed:      1,
+		Fails:      2,
+	}
+	pl := new(crawler.ProxyList)
+	assert.Nil(pl.SetStore(bs))
+	pl.Add(p)
+	assert.Nil(bs.Close())
+
+	// 重新打开后加载
+	bs, err = NewBoltStore(file)
+	assert.Nil(err)
+	defer bs.Close()
+	pl = new(crawler.ProxyList)
+	assert.Nil(pl.SetStore(bs))
+	assert.Equal(1, pl.Size())
+	assert.Equal(p, pl.List()[0])
+
+	pl.Remove(p)
+	list, err := bs.Load()
+	assert.Nil(err)
+	assert.Equal(0, len(list))
+}
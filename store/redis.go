@@ -0,0 +1,159 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/vicanso/proxy-pool/crawler"
+)
+
+const (
+	defaultRedisPrefix = "proxy-pool"
+)
+
+// 仅在锁仍由当前实例持有时续期
+var extendLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type (
+	// RedisStore redis store, the proxies can be shared by several instances
+	RedisStore struct {
+		client *redis.Client
+		prefix string
+		// 实例的标识，作为锁的值
+		id string
+	}
+	// RedisOptions redis options
+	RedisOptions struct {
+		Addr     string
+		Password string
+		DB       int
+		// Prefix prefix of the redis key
+		Prefix string
+	}
+)
+
+// NewRedisStore create a new redis store
+func NewRedisStore(opts RedisOptions) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+	err := client.Ping().Err()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	buf := make([]byte, 16)
+	_, err = rand.Read(buf)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+		id:     hex.EncodeToString(buf),
+	}, nil
+}
+
+func (rs *RedisStore) proxiesKey() string {
+	return rs.prefix + ":proxies"
+}
+
+// Load load all proxies from redis
+func (rs *RedisStore) Load() (list []*crawler.Proxy, err error) {
+	result, err := rs.client.HGetAll(rs.proxiesKey()).Result()
+	if err != nil {
+		return
+	}
+	list = make([]*crawler.Proxy, 0, len(result))
+	for _, value := range result {
+		p := new(crawler.Proxy)
+		err = json.Unmarshal([]byte(value), p)
+		if err != nil {
+			return
+		}
+		list = append(list, p)
+	}
+	return
+}
+
+// Save save the proxies to redis
+func (rs *RedisStore) Save(list ...*crawler.Proxy) error {
+	if len(list) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(list))
+	for _, p := range list {
+		buf, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		values[p.Key()] = buf
+	}
+	return rs.client.HSet(rs.proxiesKey(), values).Err()
+}
+
+// Remove remove the proxies from redis
+func (rs *RedisStore) Remove(list ...*crawler.Proxy) error {
+	if len(list) == 0 {
+		return nil
+	}
+	keys := make([]string, len(list))
+	for i, p := range list {
+		keys[i] = p.Key()
+	}
+	return rs.client.HDel(rs.proxiesKey(), keys...).Err()
+}
+
+func (rs *RedisStore) lockKey(name string) string {
+	return rs.prefix + ":lock:" + name
+}
+
+// Lock get the lock of name, it will be released after ttl
+func (rs *RedisStore) Lock(name string, ttl time.Duration) (bool, error) {
+	return rs.client.SetNX(rs.lockKey(name), rs.id, ttl).Result()
+}
+
+// Extend extend the ttl of the lock, it fails if the lock isn't held by the store
+func (rs *RedisStore) Extend(name string, ttl time.Duration) (bool, error) {
+	result, err := extendLockScript.Run(rs.client, []string{
+		rs.lockKey(name),
+	}, rs.id, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return result == 1, nil
+}
+
+// Close close the redis client
+func (rs *RedisStore) Close() error {
+	return rs.client.Close()
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 tree xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/vicanso/proxy-pool/crawler"
+)
+
+func TestRedisStore(t *testing.T) {
+	assert := assert.New(t)
+	mr, err := miniredis.Run()
+	assert.Nil(err)
+	defer mr.Close()
+
+	newStore := func() *RedisStore {
+		rs, err := NewRedisStore(RedisOptions{
+			Addr: mr.Addr(),
+		})
+		assert.Nil(err)
+		return rs
+	}
+	rs1 := newStore()
+	defer rs1.Close()
+	rs2 := newStore()
+	defer rs2.Close()
+
+	// 两个实例共享代理列表
+	pl1 := new(crawler.ProxyList)
+	assert.Nil(pl1.SetStore(rs1))
+	pl2 := new(crawler.ProxyList)
+	assert.Nil(pl2.SetStore(rs2))
+
+	p := &crawler.Proxy{
+		IP:       "127.0.0.1",
+		Port:     "80",
+		Category: "http",
+		Speed:    1,
+	}
+	pl1.Add(p)
+	assert.Nil(pl2.Reload())
+	assert.Equal(1, pl2.Size())
+	assert.Equal(p, pl2.List()[0])
+
+	// 失败次数共享
+	p.Fails = 2
+	pl1.Save(p)
+	assert.Nil(pl2.Reload())
+	assert.Equal(int32(2), pl2.List()[0].Fails)
+
+	pl1.Remove(p)
+	assert.Nil(pl2.Reload())
+	assert.Equal(0, pl2.Size())
+
+	// 只有一个实例可获取锁
+	locked, err := pl1.Acquire("redetect", time.Minute)
+	assert.Nil(err)
+	assert.True(locked)
+	locked, err = pl2.Acquire("redetect", time.Minute)
+	assert.Nil(err)
+	assert.False(locked)
+	mr.FastForward(time.Minute)
+	locked, err = pl2.Acquire("redetect", time.Minute)
+	assert.Nil(err)
+	assert.True(locked)
+
+	// 只有持有锁的实例可续期
+	extended, err := pl1.Extend("redetect", time.Minute)
+	assert.Nil(err)
+	assert.False(extended)
+	mr.FastForward(30 * time.Second)
+	extended, err = pl2.Extend("redetect", time.Minute)
+	assert.Nil(err)
+	assert.True(extended)
+	mr.FastForward(45 * time.Second)
+	locked, err = pl1.Acquire("redetect", time.Minute)
+	assert.Nil(err)
+	assert.False(locked)
+}
+
+func TestRedisStoreRedetect(t *testing.T) {
+	assert := assert.New(t)
+	mr, err := miniredis.Run()
+	assert.Nil(err)
+	defer mr.Close()
+
+	rs1, err := NewRedisStore(RedisOptions{
+		Addr: mr.Addr(),
+	})
+	assert.Nil(err)
+	defer rs1.Close()
+	rs2, err := NewRedisStore(RedisOptions{
+		Addr: mr.Addr(),
+	})
+	assert.Nil(err)
+	defer rs2.Close()
+
+	c1 := new(crawler.Crawler)
+	assert.Nil(c1.SetStore(rs1))
+	pl2 := new(crawler.ProxyList)
+	assert.Nil(pl2.SetStore(rs2))
+
+	// 其它实例新增的代理，获取到锁的实例检测前从存储中加载
+	pl2.Add(&crawler.Proxy{
+		IP:       "127.0.0.1",
+		Port:     "1",
+		Category: "http",
+	})
+	c1.RedetectAvailableProxy()
+	list := c1.GetAvailableProxyList()
+	assert.Equal(1, len(list))
+	assert.Equal(int32(1), list[0].Fails)
+	assert.NotEqual(int64(0), list[0].DetectedAt)
+}